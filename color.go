@@ -0,0 +1,26 @@
+package main
+
+import "os"
+
+const (
+	colorGreen = "32"
+	colorRed   = "31"
+)
+
+var colorEnabled = false
+
+func setupColor(noColor bool) {
+	colorEnabled = !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func colorize(code, text string) string {
+	if !colorEnabled {
+		return text
+	}
+	return "\033[" + code + "m" + text + "\033[0m"
+}
@@ -0,0 +1,730 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"commet/pkg/commet"
+)
+
+const version = "0.1.0"
+
+// repoCommands need an initialized repository in the current directory.
+// config, hash-object, diff, apply and mirror can run without one.
+var repoCommands = map[string]bool{
+	"add": true, "commit": true, "reset": true, "status": true, "clean": true,
+	"remote": true, "squash": true, "rev-parse": true, "ahead-behind": true,
+	"notes": true, "check-ignore": true, "ls-tree": true, "graph": true,
+	"repair": true, "fsck": true, "verify": true, "prune-empty": true,
+}
+
+func requireRepo(repo *commet.Repo) {
+	if !repo.Exists() {
+		fmt.Fprintln(os.Stderr, "fatal: not a commet repository (run 'commet init')")
+		os.Exit(128)
+	}
+}
+
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		rest := flags.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func printStatus(w io.Writer, report *commet.StatusReport, showIgnored bool) {
+	if len(report.Staged) == 0 {
+		fmt.Fprintln(w, "No changes staged.")
+	} else {
+		fmt.Fprintln(w, "Changes staged:")
+		for _, path := range report.Staged {
+			fmt.Fprintf(w, "- %s\n", colorize(colorGreen, commet.QuotePath(path)))
+		}
+	}
+	modified, deleted := map[string]bool{}, map[string]bool{}
+	for _, path := range report.Modified {
+		modified[path] = true
+	}
+	for _, path := range report.Deleted {
+		deleted[path] = true
+	}
+	stale := len(report.Modified) + len(report.Deleted)
+	if stale > 0 {
+		fmt.Fprintln(w, "Staged, but with unstaged changes:")
+		for _, path := range report.Staged {
+			if deleted[path] {
+				fmt.Fprintf(w, "- %s\n", colorize(colorRed, "deleted:  "+commet.QuotePath(path)))
+			} else if modified[path] {
+				fmt.Fprintf(w, "- %s\n", colorize(colorRed, "modified: "+commet.QuotePath(path)))
+			}
+		}
+	}
+	if len(report.Untracked) > 0 {
+		fmt.Fprintln(w, "Untracked files:")
+		for _, path := range report.Untracked {
+			fmt.Fprintf(w, "- %s\n", colorize(colorRed, commet.QuotePath(path)))
+		}
+	}
+	if showIgnored && len(report.Ignored) > 0 {
+		fmt.Fprintln(w, "Ignored files:")
+		for _, path := range report.Ignored {
+			fmt.Fprintf(w, "- %s\n", commet.QuotePath(path))
+		}
+	}
+	var counts []string
+	for _, count := range []struct {
+		n     int
+		label string
+	}{
+		{len(report.Staged), "staged"},
+		{len(report.Modified), "modified"},
+		{len(report.Deleted), "deleted"},
+		{len(report.Untracked), "untracked"},
+	} {
+		if count.n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count.n, count.label))
+		}
+	}
+	if showIgnored && len(report.Ignored) > 0 {
+		counts = append(counts, fmt.Sprintf("%d ignored", len(report.Ignored)))
+	}
+	if len(counts) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, strings.Join(counts, ", "))
+	}
+}
+
+// printDiff colors the removed and added lines of a unified diff, leaving
+// the two file header lines alone.
+func printDiff(w io.Writer, diff string) {
+	for i, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case i < 2 || line == "":
+		case line[0] == '-':
+			line = colorize(colorRed, strings.TrimSuffix(line, "\n")) + "\n"
+		case line[0] == '+':
+			line = colorize(colorGreen, strings.TrimSuffix(line, "\n")) + "\n"
+		}
+		fmt.Fprint(w, line)
+	}
+}
+
+func printHelp() {
+	fmt.Println("Commet - A simple Git-like tool written in Go")
+	fmt.Println("\nUsage:")
+	fmt.Println("  commet [command] [options]")
+	fmt.Println()
+	fmt.Println("Available commands:")
+	fmt.Println("  init      Initialize a new repository")
+	fmt.Println("  add       Stage files or directories")
+	fmt.Println("  commit    Commit staged changes (-a to include modified tracked files)")
+	fmt.Println("  status    Show the status of the repository")
+	fmt.Println("  reset     Unstage files ([HEAD] <file>...)")
+	fmt.Println("  config    Get, set, --list or --unset configuration values")
+	fmt.Println("  remote    Manage remote repositories (add, remove, -v)")
+	fmt.Println("  squash    Combine a range of commits (<base>..<tip> -m <message>)")
+	fmt.Println("  prune-empty  Drop commits that change nothing (--force to rewrite history)")
+	fmt.Println("  rev-parse Print the full commit hash for a revision (HEAD, HEAD~2, <prefix>)")
+	fmt.Println("  ahead-behind  Count commits HEAD is ahead and behind of <ref>")
+	fmt.Println("  notes     Attach notes to commits (add <rev> -m <text>, show, remove)")
+	fmt.Println("  check-ignore  Show which paths are ignored (-v for the matching rule)")
+	fmt.Println("  ls-tree   List the files in a commit's tree (<rev> [--name-only] [-r])")
+	fmt.Println("  graph     Print the commit graph (--dot for Graphviz)")
+	fmt.Println("  hash-object  Print the hash of a file (-w to store it, --stdin to read stdin)")
+	fmt.Println("  diff      Compare two files outside a repository (--no-index <a> <b>)")
+	fmt.Println("  apply     Apply a unified diff to the working tree")
+	fmt.Println("  clean     Remove untracked files (-n to preview, -f to remove)")
+	fmt.Println("  repair    Fix a dangling HEAD and drop unreadable staging entries")
+	fmt.Println("  fsck      List dangling commits (--lost-found to save refs to them)")
+	fmt.Println("  verify    Rehash one commit or blob and check it against its name")
+	fmt.Println("  mirror    Copy all objects and refs from one repository to another (<src> <dst>)")
+	fmt.Println("  -v        Show version information")
+	fmt.Println("  --no-color  Disable colored output (also honors NO_COLOR)")
+	fmt.Println("\nUse 'commet [command] -h' for more information about a command.")
+}
+
+func main() {
+	versionFlag := flag.Bool("v", false, "Show version information")
+	helpFlag := flag.Bool("help", false, "Show help")
+	noColorFlag := flag.Bool("no-color", false, "Disable colored output")
+	flag.Parse()
+	setupColor(*noColorFlag)
+
+	if *versionFlag {
+		fmt.Println("Commet version:", version)
+		return
+	}
+
+	if *helpFlag || flag.NArg() == 0 {
+		printHelp()
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	repo := commet.NewRepo("./")
+	if repoCommands[flag.Arg(0)] {
+		requireRepo(repo)
+	}
+	switch flag.Arg(0) {
+	case "init":
+		err := repo.Init()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("Initialized empty repository in", repo.RepoDir)
+	case "add":
+		addFlags := flag.NewFlagSet("add", flag.ExitOnError)
+		forceFlag := addFlags.Bool("force", false, "Stage files even if they exceed core.maxBlobSize")
+		quietFlag := addFlags.Bool("quiet", false, "Do not report progress or staged files")
+		paths := parseArgs(addFlags, flag.Args()[1:])
+		if len(paths) < 1 {
+			fmt.Println("Error: You must specify a file to add.")
+			return
+		}
+		var report func(done, total int)
+		if !*quietFlag {
+			report = newProgress("Staging", os.Stderr).Update
+		}
+		files, err := repo.AddFilesContext(ctx, paths, *forceFlag, report)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if *quietFlag {
+			return
+		}
+		if len(files) == 1 {
+			fmt.Printf("Added %s to staging area\n", files[0])
+		} else {
+			fmt.Printf("Added %d files to staging area\n", len(files))
+		}
+	case "commit":
+		commitFlags := flag.NewFlagSet("commit", flag.ExitOnError)
+		authorFlag := commitFlags.String("author", "", "Override the commit author (\"Name <email>\")")
+		dateFlag := commitFlags.String("date", "", "Override the commit date (RFC 3339)")
+		signoffFlag := commitFlags.Bool("s", false, "Add a Signed-off-by trailer from user.name and user.email")
+		messageFlag := commitFlags.String("m", "", "Commit message")
+		onlyFlag := commitFlags.Bool("only", false, "Commit only the given paths, leaving other staged files staged")
+		fixupFlag := commitFlags.String("fixup", "", "Create a \"fixup! \" commit for the given revision")
+		squashFlag := commitFlags.String("squash", "", "Create a \"squash! \" commit for the given revision")
+		allFlag := commitFlags.Bool("a", false, "Also commit modified tracked files")
+		noVerifyFlag := commitFlags.Bool("no-verify", false, "Skip the pre-commit and commit-msg hooks")
+		quietFlag := commitFlags.Bool("quiet", false, "Do not report the new commit")
+		porcelainFlag := commitFlags.Bool("porcelain", false, "Print only the new commit hash")
+		args := parseArgs(commitFlags, flag.Args()[1:])
+		opts := commet.CommitOptions{Author: *authorFlag, Date: *dateFlag, Signoff: *signoffFlag, Fixup: *fixupFlag, Squash: *squashFlag, NoVerify: *noVerifyFlag, All: *allFlag}
+		message := *messageFlag
+		if *onlyFlag {
+			if len(args) == 0 {
+				fmt.Println("Error: You must specify the paths to commit with --only.")
+				return
+			}
+			opts.Only = args
+		} else if message == "" && len(args) > 0 {
+			message = args[0]
+		}
+		if message == "" && opts.Fixup == "" && opts.Squash == "" {
+			fmt.Println("Error: You must provide a commit message.")
+			return
+		}
+		hash, err := repo.Commit(message, opts)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if *porcelainFlag {
+			fmt.Println(hash)
+		} else if !*quietFlag {
+			commit, err := repo.ReadCommit(hash)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("Commit successful:", commit.Message)
+		}
+	case "reset":
+		resetFlags := flag.NewFlagSet("reset", flag.ExitOnError)
+		paths := parseArgs(resetFlags, flag.Args()[1:])
+		if len(paths) > 0 && paths[0] == "HEAD" {
+			paths = paths[1:]
+		}
+		if len(paths) == 0 {
+			fmt.Println("Error: You must specify the files to unstage; resetting to a commit is not supported.")
+			return
+		}
+		unstaged, err := repo.Reset(paths)
+		for _, path := range unstaged {
+			fmt.Printf("Unstaged %s\n", commet.QuotePath(path))
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+	case "status":
+		statusFlags := flag.NewFlagSet("status", flag.ExitOnError)
+		ignoredFlag := statusFlags.Bool("ignored", false, "Also list files matched by .commetignore")
+		untrackedFlag := statusFlags.String("untracked-files", "normal", "Untracked files to show: no, normal or all")
+		noUntrackedFlag := statusFlags.Bool("uno", false, "Do not show untracked files")
+		normalUntrackedFlag := statusFlags.Bool("unormal", false, "Show untracked files, collapsing untracked directories")
+		allUntrackedFlag := statusFlags.Bool("uall", false, "Show every untracked file")
+		statusNoColorFlag := statusFlags.Bool("no-color", false, "Disable colored output")
+		statusFlags.Parse(flag.Args()[1:])
+		if *statusNoColorFlag {
+			setupColor(true)
+		}
+		untrackedMode := *untrackedFlag
+		switch {
+		case *noUntrackedFlag:
+			untrackedMode = "no"
+		case *allUntrackedFlag:
+			untrackedMode = "all"
+		case *normalUntrackedFlag:
+			untrackedMode = "normal"
+		}
+		if untrackedMode != "no" && untrackedMode != "normal" && untrackedMode != "all" {
+			fmt.Println("Error: Invalid untracked files mode:", untrackedMode)
+			return
+		}
+		report, err := repo.Status(*ignoredFlag, untrackedMode)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		printStatus(os.Stdout, report, *ignoredFlag)
+	case "clean":
+		cleanFlags := flag.NewFlagSet("clean", flag.ExitOnError)
+		dryRunFlag := cleanFlags.Bool("n", false, "Only list the files that would be removed")
+		forceFlag := cleanFlags.Bool("f", false, "Remove untracked files")
+		ignoredFlag := cleanFlags.Bool("x", false, "Also remove files matched by .commetignore")
+		cleanFlags.Parse(flag.Args()[1:])
+		if !*dryRunFlag && !*forceFlag {
+			fmt.Println("Error: Refusing to clean without -f; use -n to see what would be removed.")
+			return
+		}
+		removed, err := repo.Clean(*dryRunFlag, *ignoredFlag)
+		for _, path := range removed {
+			if *dryRunFlag {
+				fmt.Println("Would remove", commet.QuotePath(path))
+			} else {
+				fmt.Println("Removing", commet.QuotePath(path))
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+	case "remote":
+		remoteFlags := flag.NewFlagSet("remote", flag.ExitOnError)
+		verboseFlag := remoteFlags.Bool("v", false, "Show remote URLs")
+		remoteFlags.Parse(flag.Args()[1:])
+		switch remoteFlags.Arg(0) {
+		case "add":
+			if remoteFlags.NArg() < 3 {
+				fmt.Println("Error: Usage: commet remote add <name> <url>")
+				return
+			}
+			err := repo.AddRemote(remoteFlags.Arg(1), remoteFlags.Arg(2))
+			if err != nil {
+				fmt.Println(err)
+			}
+		case "remove":
+			if remoteFlags.NArg() < 2 {
+				fmt.Println("Error: Usage: commet remote remove <name>")
+				return
+			}
+			err := repo.RemoveRemote(remoteFlags.Arg(1))
+			if err != nil {
+				fmt.Println(err)
+			}
+		case "":
+			remotes, err := repo.Remotes()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			names := make([]string, 0, len(remotes))
+			for name := range remotes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if *verboseFlag {
+					fmt.Printf("%s\t%s\n", name, remotes[name])
+				} else {
+					fmt.Println(name)
+				}
+			}
+		default:
+			fmt.Println("Error: Unknown remote subcommand:", remoteFlags.Arg(0))
+		}
+	case "squash":
+		squashFlags := flag.NewFlagSet("squash", flag.ExitOnError)
+		messageFlag := squashFlags.String("m", "", "Message for the combined commit")
+		args := parseArgs(squashFlags, flag.Args()[1:])
+		if len(args) < 1 {
+			fmt.Println("Error: You must specify a range as <base>..<tip>.")
+			return
+		}
+		base, tip, ok := strings.Cut(args[0], "..")
+		if !ok || base == "" || tip == "" {
+			fmt.Println("Error: You must specify a range as <base>..<tip>.")
+			return
+		}
+		if *messageFlag == "" {
+			fmt.Println("Error: You must provide a message with -m.")
+			return
+		}
+		hash, count, err := repo.Squash(base, tip, *messageFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Squashed %d commits into %s\n", count, hash)
+	case "prune-empty":
+		pruneFlags := flag.NewFlagSet("prune-empty", flag.ExitOnError)
+		forceFlag := pruneFlags.Bool("force", false, "Rewrite history without asking")
+		pruneFlags.Parse(flag.Args()[1:])
+		if !*forceFlag {
+			fmt.Println("Error: prune-empty rewrites history; rerun with --force to proceed.")
+			os.Exit(128)
+		}
+		pruned, err := repo.PruneEmpty()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Pruned %d empty commits\n", pruned)
+	case "rev-parse":
+		if flag.NArg() < 2 {
+			fmt.Println("Error: You must specify a revision.")
+			return
+		}
+		for _, rev := range flag.Args()[1:] {
+			hash, err := repo.RevParse(rev)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println(hash)
+		}
+	case "ahead-behind":
+		if flag.NArg() < 2 {
+			fmt.Println("Error: You must specify a revision to compare against.")
+			return
+		}
+		ahead, behind, err := repo.AheadBehind("HEAD", flag.Arg(1))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("ahead %d, behind %d\n", ahead, behind)
+	case "notes":
+		notesFlags := flag.NewFlagSet("notes", flag.ExitOnError)
+		messageFlag := notesFlags.String("m", "", "Note text")
+		forceFlag := notesFlags.Bool("f", false, "Overwrite an existing note")
+		args := parseArgs(notesFlags, flag.Args()[1:])
+		if len(args) < 2 {
+			fmt.Println("Error: Usage: commet notes (add|show|remove) <rev>")
+			return
+		}
+		switch args[0] {
+		case "add":
+			if *messageFlag == "" {
+				fmt.Println("Error: You must provide the note text with -m.")
+				return
+			}
+			err := repo.AddNote(args[1], *messageFlag, *forceFlag)
+			if err != nil {
+				fmt.Println(err)
+			}
+		case "show":
+			note, err := repo.Note(args[1])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println(note)
+		case "remove":
+			err := repo.RemoveNote(args[1])
+			if err != nil {
+				fmt.Println(err)
+			}
+		default:
+			fmt.Println("Error: Unknown notes subcommand:", args[0])
+		}
+	case "check-ignore":
+		checkFlags := flag.NewFlagSet("check-ignore", flag.ExitOnError)
+		verboseFlag := checkFlags.Bool("v", false, "Show the matching pattern and where it is defined")
+		paths := parseArgs(checkFlags, flag.Args()[1:])
+		if len(paths) == 0 {
+			fmt.Println("Error: You must specify at least one path.")
+			os.Exit(128)
+		}
+		matches, err := repo.CheckIgnore(paths)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(128)
+		}
+		for _, match := range matches {
+			if *verboseFlag {
+				fmt.Printf("%s:%d:%s\t%s\n", match.Source, match.Line, match.Pattern, commet.QuotePath(match.Path))
+			} else {
+				fmt.Println(commet.QuotePath(match.Path))
+			}
+		}
+		if len(matches) == 0 {
+			os.Exit(1)
+		}
+	case "ls-tree":
+		lsTreeFlags := flag.NewFlagSet("ls-tree", flag.ExitOnError)
+		nameOnlyFlag := lsTreeFlags.Bool("name-only", false, "List only file names")
+		recursiveFlag := lsTreeFlags.Bool("r", false, "Recurse into subdirectories")
+		args := parseArgs(lsTreeFlags, flag.Args()[1:])
+		if len(args) < 1 {
+			fmt.Println("Error: You must specify a commit.")
+			return
+		}
+		entries, err := repo.LsTree(args[0], *recursiveFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, entry := range entries {
+			if *nameOnlyFlag {
+				fmt.Println(commet.QuotePath(entry.Path))
+				continue
+			}
+			kind, hash := "blob", entry.Hash
+			if strings.HasSuffix(entry.Path, "/") {
+				kind = "tree"
+			}
+			if hash == "" {
+				hash = "-"
+			}
+			fmt.Printf("%s %s %s\t%s\n", entry.Mode, kind, hash, commet.QuotePath(entry.Path))
+		}
+	case "graph":
+		graphFlags := flag.NewFlagSet("graph", flag.ExitOnError)
+		dotFlag := graphFlags.Bool("dot", false, "Write the commit graph in Graphviz DOT format")
+		graphFlags.Parse(flag.Args()[1:])
+		if !*dotFlag {
+			fmt.Println("Error: Only --dot output is supported.")
+			return
+		}
+		err := repo.WriteDOT(os.Stdout)
+		if err != nil {
+			fmt.Println(err)
+		}
+	case "hash-object":
+		hashFlags := flag.NewFlagSet("hash-object", flag.ExitOnError)
+		writeFlag := hashFlags.Bool("w", false, "Write the object into the object store")
+		stdinFlag := hashFlags.Bool("stdin", false, "Read the content from stdin")
+		paths := parseArgs(hashFlags, flag.Args()[1:])
+		if *writeFlag {
+			requireRepo(repo)
+		}
+		if *stdinFlag {
+			hash, err := repo.HashObjectReader(os.Stdin, *writeFlag)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println(hash)
+		}
+		if !*stdinFlag && len(paths) == 0 {
+			fmt.Println("Error: You must specify a file or --stdin.")
+			return
+		}
+		for _, path := range paths {
+			hash, err := repo.HashObject(path, *writeFlag)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println(hash)
+		}
+	case "diff":
+		diffFlags := flag.NewFlagSet("diff", flag.ExitOnError)
+		noIndexFlag := diffFlags.Bool("no-index", false, "Compare two paths on disk")
+		diffNoColorFlag := diffFlags.Bool("no-color", false, "Disable colored output")
+		args := parseArgs(diffFlags, flag.Args()[1:])
+		if *diffNoColorFlag {
+			setupColor(true)
+		}
+		if !*noIndexFlag || len(args) != 2 {
+			fmt.Println("Error: Usage: commet diff --no-index <path> <path>")
+			os.Exit(128)
+		}
+		var out bytes.Buffer
+		differ, err := commet.DiffFiles(&out, args[0], args[1])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(128)
+		}
+		printDiff(os.Stdout, out.String())
+		if differ {
+			os.Exit(1)
+		}
+	case "apply":
+		applyFlags := flag.NewFlagSet("apply", flag.ExitOnError)
+		checkFlag := applyFlags.Bool("check", false, "Check that the patch applies without changing any files")
+		reverseFlag := applyFlags.Bool("R", false, "Apply the patch in reverse")
+		applyFlags.Parse(flag.Args()[1:])
+		if applyFlags.NArg() < 1 {
+			fmt.Println("Error: You must specify a patch file.")
+			return
+		}
+		patch := os.Stdin
+		if applyFlags.Arg(0) != "-" {
+			file, err := os.Open(applyFlags.Arg(0))
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			defer file.Close()
+			patch = file
+		}
+		applied, err := repo.Apply(patch, commet.ApplyOptions{Check: *checkFlag, Reverse: *reverseFlag})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, file := range applied {
+			if file.Deleted {
+				fmt.Println("Deleted", file.Path)
+			} else {
+				fmt.Println("Applied patch to", file.Path)
+			}
+		}
+	case "repair":
+		report, err := repo.Repair()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if report.HeadRepaired {
+			if report.NewHead == "" {
+				fmt.Printf("HEAD pointed at missing commit %s; no intact commits remain, HEAD removed\n", report.OldHead)
+			} else {
+				fmt.Printf("HEAD pointed at missing commit %s; reset to %s\n", report.OldHead, report.NewHead)
+			}
+		}
+		if report.DroppedEntries > 0 {
+			fmt.Printf("Dropped %d unreadable staging entries\n", report.DroppedEntries)
+		}
+		if report.IndexCleared {
+			fmt.Println("Cleared an unreadable staging area; re-add your files")
+		}
+		if !report.HeadRepaired && report.DroppedEntries == 0 && !report.IndexCleared {
+			fmt.Println("Nothing to repair")
+		}
+	case "fsck":
+		fsckFlags := flag.NewFlagSet("fsck", flag.ExitOnError)
+		lostFoundFlag := fsckFlags.Bool("lost-found", false, "Write refs to dangling commits under .commet/lost-found/commit/")
+		fsckFlags.Parse(flag.Args()[1:])
+		dangling, err := repo.Fsck(*lostFoundFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, hash := range dangling {
+			fmt.Println("dangling commit", hash)
+		}
+	case "verify":
+		if flag.NArg() < 2 {
+			fmt.Println("Error: You must specify an object hash.")
+			return
+		}
+		if err := repo.VerifyObject(flag.Arg(1)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(flag.Arg(1) + ": OK")
+	case "mirror":
+		if flag.NArg() < 3 {
+			fmt.Println("Error: You must specify a source and a destination repository.")
+			return
+		}
+		transferred, err := commet.MirrorContext(ctx, flag.Arg(1), flag.Arg(2))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Mirrored %s to %s: %d objects transferred\n", flag.Arg(1), flag.Arg(2), transferred)
+	case "config":
+		configFlags := flag.NewFlagSet("config", flag.ExitOnError)
+		listFlag := configFlags.Bool("list", false, "List all config values")
+		unsetFlag := configFlags.Bool("unset", false, "Remove a config key")
+		localFlag := configFlags.Bool("local", false, "Use the repository config only")
+		globalFlag := configFlags.Bool("global", false, "Use the user config only")
+		configFlags.Parse(flag.Args()[1:])
+		scope := ""
+		if *localFlag {
+			scope = "local"
+		} else if *globalFlag {
+			scope = "global"
+		}
+		if scope != "global" {
+			requireRepo(repo)
+		}
+		if *listFlag {
+			settings, err := repo.ListConfig(scope)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			keys := make([]string, 0, len(settings))
+			for key := range settings {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("%s=%s\n", key, settings[key])
+			}
+			return
+		}
+		if configFlags.NArg() < 1 {
+			fmt.Println("Error: You must specify a config key.")
+			return
+		}
+		if *unsetFlag {
+			err := repo.UnsetConfig(scope, configFlags.Arg(0))
+			if err != nil {
+				fmt.Println(err)
+			}
+			return
+		}
+		if configFlags.NArg() < 2 {
+			settings, err := repo.ListConfig(scope)
+			if err != nil {
+				fmt.Println(err)
+			} else if value := settings[configFlags.Arg(0)]; value != "" {
+				fmt.Println(value)
+			}
+			return
+		}
+		err := repo.SetConfigScope(scope, configFlags.Arg(0), configFlags.Arg(1))
+		if err != nil {
+			fmt.Println(err)
+		}
+	default:
+		printHelp()
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"commet/pkg/commet"
+)
+
+// TestMain runs the command itself instead of the tests when runCommet
+// re-executes the test binary, so the CLI is tested without a separate build.
+func TestMain(m *testing.M) {
+	if os.Getenv("COMMET_TEST_RUN_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommet runs commet with args in dir and returns its stdout, stderr and
+// exit code. The global config lives in dir, and color is off.
+func runCommet(t *testing.T, dir string, args ...string) (string, string, int) {
+	t.Helper()
+	return runCommetEnv(t, dir, []string{"NO_COLOR=1"}, args...)
+}
+
+// runCommetEnv is runCommet with env added to the environment instead of
+// NO_COLOR.
+func runCommetEnv(t *testing.T, dir string, env []string, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "COMMET_TEST_RUN_MAIN=1", "XDG_CONFIG_HOME="+filepath.Join(dir, ".config-home"))
+	cmd.Env = append(cmd.Env, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("running commet %s: %v", strings.Join(args, " "), err)
+	}
+	return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitPorcelain(t *testing.T) {
+	dir := t.TempDir()
+	runCommet(t, dir, "init")
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	runCommet(t, dir, "add", "--quiet", "a.txt")
+	stdout, stderr, code := runCommet(t, dir, "commit", "--porcelain", "-m", "first")
+	if code != 0 || stderr != "" {
+		t.Fatalf("commit --porcelain exited %d with stderr %q", code, stderr)
+	}
+	if !regexp.MustCompile(`\A[0-9a-f]{40}\n\z`).MatchString(stdout) {
+		t.Errorf("commit --porcelain printed %q, want a 40-character hash and a newline", stdout)
+	}
+	if head, _, _ := runCommet(t, dir, "rev-parse", "HEAD"); head != stdout {
+		t.Errorf("commit --porcelain printed %q, but HEAD is %q", stdout, head)
+	}
+}
+
+func TestOutsideRepository(t *testing.T) {
+	const fatal = "fatal: not a commet repository (run 'commet init')\n"
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	for _, args := range [][]string{
+		{"add", "a.txt"},
+		{"commit", "-m", "message"},
+		{"reset", "a.txt"},
+		{"status"},
+		{"clean", "-n"},
+		{"remote", "-v"},
+		{"squash", "HEAD~1..HEAD", "-m", "message"},
+		{"prune-empty", "--force"},
+		{"rev-parse", "HEAD"},
+		{"ahead-behind", "HEAD"},
+		{"notes", "show", "HEAD"},
+		{"check-ignore", "a.txt"},
+		{"ls-tree", "HEAD"},
+		{"graph", "--dot"},
+		{"repair"},
+		{"fsck"},
+		{"verify", "HEAD"},
+		{"hash-object", "-w", "a.txt"},
+		{"config", "user.name"},
+	} {
+		stdout, stderr, code := runCommet(t, dir, args...)
+		if code != 128 || stderr != fatal || stdout != "" {
+			t.Errorf("commet %s outside a repository: exit %d, stdout %q, stderr %q; want exit 128 and %q",
+				strings.Join(args, " "), code, stdout, stderr, fatal)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".commet")); !os.IsNotExist(err) {
+		t.Errorf("a command outside a repository created .commet: %v", err)
+	}
+	for _, args := range [][]string{
+		{"hash-object", "a.txt"},
+		{"config", "--global", "user.name", "Someone"},
+	} {
+		if _, stderr, code := runCommet(t, dir, args...); code != 0 {
+			t.Errorf("commet %s outside a repository: exit %d, stderr %q; want success", strings.Join(args, " "), code, stderr)
+		}
+	}
+}
+
+func TestAddProgress(t *testing.T) {
+	dir := t.TempDir()
+	runCommet(t, dir, "init")
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		writeFile(t, filepath.Join(dir, "src", name), name)
+	}
+	_, stderr, _ := runCommet(t, dir, "add", "src")
+	if stderr != "Staging 3/3 files\n" {
+		t.Errorf("add printed %q on stderr, want the final progress line", stderr)
+	}
+	_, stderr, _ = runCommet(t, dir, "add", "--quiet", "src")
+	if stderr != "" {
+		t.Errorf("add --quiet printed %q on stderr, want nothing", stderr)
+	}
+}
+
+func TestStatusColor(t *testing.T) {
+	report := &commet.StatusReport{
+		Staged:    []string{"a.txt", "b.txt"},
+		Modified:  []string{"a.txt"},
+		Untracked: []string{"c.txt"},
+	}
+	defer func(enabled bool) { colorEnabled = enabled }(colorEnabled)
+	var out bytes.Buffer
+	colorEnabled = true
+	printStatus(&out, report, false)
+	if !strings.Contains(out.String(), "\033[") {
+		t.Errorf("status with color on printed %q, want escape codes", out.String())
+	}
+	t.Setenv("NO_COLOR", "1")
+	for _, noColor := range []bool{true, false} {
+		out.Reset()
+		colorEnabled = true
+		setupColor(noColor)
+		printStatus(&out, report, false)
+		if strings.Contains(out.String(), "\033") {
+			t.Errorf("status with color off (--no-color %v, NO_COLOR=1) printed %q, want no escape codes", noColor, out.String())
+		}
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	dir := t.TempDir()
+	runCommet(t, dir, "init")
+	writeFile(t, filepath.Join(dir, "a.txt"), "one\n")
+	writeFile(t, filepath.Join(dir, "b.txt"), "two\n")
+	runCommet(t, dir, "add", "--quiet", "a.txt")
+	for _, tc := range []struct {
+		env      []string
+		args     []string
+		wantCode int
+	}{
+		{[]string{"NO_COLOR=1"}, []string{"status"}, 0},
+		{nil, []string{"--no-color", "status"}, 0},
+		{nil, []string{"status", "--no-color"}, 0},
+		{[]string{"NO_COLOR=1"}, []string{"diff", "--no-index", "a.txt", "b.txt"}, 1},
+		{nil, []string{"diff", "--no-color", "--no-index", "a.txt", "b.txt"}, 1},
+	} {
+		stdout, stderr, code := runCommetEnv(t, dir, tc.env, tc.args...)
+		if code != tc.wantCode || stderr != "" {
+			t.Errorf("%v commet %s: exit %d, stderr %q; want exit %d", tc.env, strings.Join(tc.args, " "), code, stderr, tc.wantCode)
+		}
+		if stdout == "" || strings.Contains(stdout, "\033") {
+			t.Errorf("%v commet %s printed %q, want uncolored output", tc.env, strings.Join(tc.args, " "), stdout)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	runCommet(t, dir, "init")
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	runCommet(t, dir, "add", "--quiet", "a.txt")
+	runCommet(t, dir, "commit", "-m", "first")
+	head, _, _ := runCommet(t, dir, "rev-parse", "HEAD")
+	prefix := head[:7]
+	if stdout, _, code := runCommet(t, dir, "verify", prefix); code != 0 || stdout != prefix+": OK\n" {
+		t.Errorf("verify %s: exit %d, stdout %q; want %q", prefix, code, stdout, prefix+": OK\n")
+	}
+	if _, _, code := runCommet(t, dir, "verify", "0000000"); code != 1 {
+		t.Errorf("verify of an unknown object exited %d, want 1", code)
+	}
+}
+
+func TestConfigListAndUnset(t *testing.T) {
+	dir := t.TempDir()
+	runCommet(t, dir, "init")
+	for _, args := range [][]string{
+		{"config", "--global", "user.name", "Global"},
+		{"config", "--global", "core.editor", "vi"},
+		{"config", "zeta.key", "local"},
+		{"config", "user.name", "Local"},
+		{"config", "alpha.key", "local"},
+	} {
+		if _, stderr, code := runCommet(t, dir, args...); code != 0 {
+			t.Fatalf("commet %s: exit %d, stderr %q", strings.Join(args, " "), code, stderr)
+		}
+	}
+	for _, tc := range []struct {
+		scope []string
+		want  string
+	}{
+		{nil, "alpha.key=local\ncore.editor=vi\nuser.name=Local\nzeta.key=local\n"},
+		{[]string{"--local"}, "alpha.key=local\nuser.name=Local\nzeta.key=local\n"},
+		{[]string{"--global"}, "core.editor=vi\nuser.name=Global\n"},
+	} {
+		args := append([]string{"config", "--list"}, tc.scope...)
+		for range 3 {
+			if stdout, _, _ := runCommet(t, dir, args...); stdout != tc.want {
+				t.Errorf("commet %s printed %q, want %q", strings.Join(args, " "), stdout, tc.want)
+			}
+		}
+	}
+	before, _, _ := runCommet(t, dir, "config", "--list")
+	stdout, stderr, code := runCommet(t, dir, "config", "--unset", "missing.key")
+	if code != 0 || stdout != "" || stderr != "" {
+		t.Errorf("unsetting a missing key: exit %d, stdout %q, stderr %q; want a silent success", code, stdout, stderr)
+	}
+	if after, _, _ := runCommet(t, dir, "config", "--list"); after != before {
+		t.Errorf("unsetting a missing key changed the config from %q to %q", before, after)
+	}
+	runCommet(t, dir, "config", "--unset", "user.name")
+	if stdout, _, _ := runCommet(t, dir, "config", "user.name"); stdout != "Global\n" {
+		t.Errorf("user.name = %q after unsetting the local value, want the global one", stdout)
+	}
+}
+
+func TestStatusCounts(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		report      commet.StatusReport
+		showIgnored bool
+		want        string
+	}{
+		{"clean", commet.StatusReport{}, false, ""},
+		{"staged only", commet.StatusReport{Staged: []string{"a", "b"}}, false, "2 staged"},
+		{"untracked only", commet.StatusReport{Untracked: []string{"u"}}, false, "1 untracked"},
+		{"mixed", commet.StatusReport{
+			Staged:    []string{"a", "b", "c", "d"},
+			Modified:  []string{"a", "b"},
+			Deleted:   []string{"c"},
+			Untracked: []string{"u", "v", "w"},
+		}, false, "4 staged, 2 modified, 1 deleted, 3 untracked"},
+		{"deleted only", commet.StatusReport{Staged: []string{"a"}, Deleted: []string{"a"}}, false, "1 staged, 1 deleted"},
+		{"ignored", commet.StatusReport{Staged: []string{"a"}, Ignored: []string{"x.log"}}, true, "1 staged, 1 ignored"},
+		{"ignored hidden", commet.StatusReport{Staged: []string{"a"}, Ignored: []string{"x.log"}}, false, "1 staged"},
+		{"no ignored", commet.StatusReport{Staged: []string{"a"}}, true, "1 staged"},
+	} {
+		var out bytes.Buffer
+		printStatus(&out, &tc.report, tc.showIgnored)
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		footer := ""
+		if n := len(lines); n >= 2 && lines[n-2] == "" {
+			footer = lines[n-1]
+		}
+		if footer != tc.want {
+			t.Errorf("%s: footer = %q, want %q\n%s", tc.name, footer, tc.want, out.String())
+		}
+	}
+}
+
+func TestLsTreeOutput(t *testing.T) {
+	dir := t.TempDir()
+	runCommet(t, dir, "init")
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	writeFile(t, filepath.Join(dir, "src", "b.txt"), "b")
+	runCommet(t, dir, "add", "--quiet", "a.txt", "src")
+	runCommet(t, dir, "commit", "-m", "first")
+	hashA, _, _ := runCommet(t, dir, "hash-object", "a.txt")
+	hashB, _, _ := runCommet(t, dir, "hash-object", filepath.Join("src", "b.txt"))
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ls-tree", "HEAD"}, "100644 blob " + strings.TrimSpace(hashA) + "\ta.txt\n040000 tree -\tsrc/\n"},
+		{[]string{"ls-tree", "-r", "HEAD"}, "100644 blob " + strings.TrimSpace(hashA) + "\ta.txt\n100644 blob " + strings.TrimSpace(hashB) + "\tsrc/b.txt\n"},
+		{[]string{"ls-tree", "--name-only", "-r", "HEAD"}, "a.txt\nsrc/b.txt\n"},
+	} {
+		if stdout, _, _ := runCommet(t, dir, tc.args...); stdout != tc.want {
+			t.Errorf("commet %s printed %q, want %q", strings.Join(tc.args, " "), stdout, tc.want)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package commet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type ApplyOptions struct {
+	Check   bool
+	Reverse bool
+}
+
+type AppliedFile struct {
+	Path    string
+	Deleted bool
+}
+
+type patchHunk struct {
+	oldStart, oldCount int
+	newStart, newCount int
+	lines              []string
+}
+
+type filePatch struct {
+	oldPath, newPath string
+	hunks            []*patchHunk
+}
+
+// Apply checks every file in patch before writing any of them and returns
+// the files it changed. With opts.Check nothing is written. A patch that
+// renames a file writes the new path and removes the old one.
+func (r *Repo) Apply(patch io.Reader, opts ApplyOptions) ([]AppliedFile, error) {
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no valid patches in input")
+	}
+	results := make([][]string, len(files))
+	for i, file := range files {
+		if opts.Reverse {
+			file.reverse()
+		}
+		for _, path := range []string{file.oldPath, file.newPath} {
+			if err := r.checkPatchPath(path); err != nil {
+				return nil, err
+			}
+		}
+		results[i], err = r.applyFilePatch(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Check {
+		return nil, nil
+	}
+	var applied []AppliedFile
+	for i, file := range files {
+		if file.newPath == "/dev/null" {
+			if err := os.Remove(filepath.Join(r.RepoDir, file.oldPath)); err != nil {
+				return applied, err
+			}
+			applied = append(applied, AppliedFile{Path: file.oldPath, Deleted: true})
+			continue
+		}
+		target := filepath.Join(r.RepoDir, file.newPath)
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(target); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return applied, err
+		}
+		if err := os.WriteFile(target, []byte(strings.Join(results[i], "")), mode); err != nil {
+			return applied, err
+		}
+		applied = append(applied, AppliedFile{Path: file.newPath})
+		if file.isRename() {
+			if err := os.Remove(filepath.Join(r.RepoDir, file.oldPath)); err != nil {
+				return applied, err
+			}
+			applied = append(applied, AppliedFile{Path: file.oldPath, Deleted: true})
+		}
+	}
+	return applied, nil
+}
+
+// checkPatchPath rejects paths that would reach outside the working tree or
+// into .commet, either as written or once symbolic links along the way are
+// followed.
+func (r *Repo) checkPatchPath(path string) error {
+	if path == "/dev/null" {
+		return nil
+	}
+	if filepath.IsAbs(path) || isOutside(path) {
+		return fmt.Errorf("%s: path is outside the working tree", path)
+	}
+	if isInVcsDir(path) {
+		return fmt.Errorf("%s: path is inside the .commet directory", path)
+	}
+	root, err := filepath.Abs(r.RepoDir)
+	if err != nil {
+		return err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return err
+	}
+	// A new file may be created in directories that do not exist yet, so
+	// resolve the longest part of the path that does.
+	target := filepath.Join(root, path)
+	resolved, err := filepath.EvalSymlinks(target)
+	for os.IsNotExist(err) && target != root {
+		target = filepath.Dir(target)
+		resolved, err = filepath.EvalSymlinks(target)
+	}
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || isOutside(rel) {
+		return fmt.Errorf("%s: path leads outside the working tree through a symbolic link", path)
+	}
+	if isInVcsDir(rel) {
+		return fmt.Errorf("%s: path leads into the .commet directory through a symbolic link", path)
+	}
+	return nil
+}
+
+func isOutside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isInVcsDir(rel string) bool {
+	first, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	return strings.EqualFold(first, ".commet")
+}
+
+func (r *Repo) applyFilePatch(file *filePatch) ([]string, error) {
+	var lines []string
+	path := file.oldPath
+	if file.oldPath == "/dev/null" || file.isRename() {
+		if _, err := os.Stat(filepath.Join(r.RepoDir, file.newPath)); err == nil {
+			return nil, fmt.Errorf("%s: already exists in working directory", file.newPath)
+		}
+	}
+	if path == "/dev/null" {
+		path = file.newPath
+	} else {
+		data, err := os.ReadFile(filepath.Join(r.RepoDir, path))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		lines = splitLines(string(data))
+	}
+	var out []string
+	pos := 0
+	for _, hunk := range file.hunks {
+		var oldLines, newLines []string
+		for _, line := range hunk.lines {
+			switch line[0] {
+			case ' ':
+				oldLines = append(oldLines, line[1:])
+				newLines = append(newLines, line[1:])
+			case '-':
+				oldLines = append(oldLines, line[1:])
+			case '+':
+				newLines = append(newLines, line[1:])
+			}
+		}
+		start := hunk.oldStart - 1
+		if hunk.oldCount == 0 {
+			start = hunk.oldStart
+		}
+		at := findHunk(lines, oldLines, start, pos)
+		if at < 0 {
+			return nil, fmt.Errorf("patch failed: %s:%d: hunk does not apply", path, hunk.oldStart)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, newLines...)
+		pos = at + len(oldLines)
+	}
+	out = append(out, lines[pos:]...)
+	if file.newPath == "/dev/null" && len(out) > 0 {
+		return nil, fmt.Errorf("patch failed: %s: file is not empty after removing its contents", path)
+	}
+	return out, nil
+}
+
+func findHunk(lines, oldLines []string, start, min int) int {
+	matches := func(at int) bool {
+		if at < min || at+len(oldLines) > len(lines) {
+			return false
+		}
+		for i, line := range oldLines {
+			if lines[at+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for offset := 0; start-offset >= min || start+offset <= len(lines); offset++ {
+		if matches(start + offset) {
+			return start + offset
+		}
+		if offset > 0 && matches(start-offset) {
+			return start - offset
+		}
+	}
+	return -1
+}
+
+// isRename reports whether the patch moves a file to a new path.
+func (f *filePatch) isRename() bool {
+	return f.oldPath != f.newPath && f.oldPath != "/dev/null" && f.newPath != "/dev/null"
+}
+
+func (f *filePatch) reverse() {
+	f.oldPath, f.newPath = f.newPath, f.oldPath
+	for _, hunk := range f.hunks {
+		hunk.oldStart, hunk.newStart = hunk.newStart, hunk.oldStart
+		hunk.oldCount, hunk.newCount = hunk.newCount, hunk.oldCount
+		for i, line := range hunk.lines {
+			switch line[0] {
+			case '-':
+				hunk.lines[i] = "+" + line[1:]
+			case '+':
+				hunk.lines[i] = "-" + line[1:]
+			}
+		}
+	}
+}
+
+func splitLines(content string) []string {
+	var lines []string
+	for content != "" {
+		i := strings.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, content)
+			break
+		}
+		lines = append(lines, content[:i+1])
+		content = content[i+1:]
+	}
+	return lines
+}
+
+func parsePatch(patch io.Reader) ([]*filePatch, error) {
+	reader := bufio.NewReader(patch)
+	var files []*filePatch
+	var current *filePatch
+	lineNo := 0
+	readLine := func() (string, bool, error) {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return "", false, nil
+		}
+		if err != nil && err != io.EOF {
+			return "", false, err
+		}
+		lineNo++
+		return strings.TrimSuffix(line, "\n"), true, nil
+	}
+	for {
+		line, ok, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			current = &filePatch{oldPath: patchPath(line[4:])}
+		case strings.HasPrefix(line, "+++ "):
+			if current == nil {
+				return nil, fmt.Errorf("malformed patch at line %d: '+++' without '---'", lineNo)
+			}
+			current.newPath = patchPath(line[4:])
+			files = append(files, current)
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil || current.newPath == "" {
+				return nil, fmt.Errorf("malformed patch at line %d: hunk without file header", lineNo)
+			}
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("malformed patch at line %d: %v", lineNo, err)
+			}
+			oldLeft, newLeft := hunk.oldCount, hunk.newCount
+			for oldLeft > 0 || newLeft > 0 {
+				line, ok, err := readLine()
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					return nil, fmt.Errorf("malformed patch at line %d: unexpected end of hunk", lineNo)
+				}
+				if line == "" {
+					line = " "
+				}
+				switch line[0] {
+				case ' ':
+					oldLeft--
+					newLeft--
+				case '-':
+					oldLeft--
+				case '+':
+					newLeft--
+				case '\\':
+					trimLastNewline(hunk)
+					continue
+				default:
+					return nil, fmt.Errorf("malformed patch at line %d: unexpected line %q", lineNo, line)
+				}
+				hunk.lines = append(hunk.lines, line+"\n")
+			}
+			if next, err := reader.Peek(1); err == nil && next[0] == '\\' {
+				readLine()
+				trimLastNewline(hunk)
+			}
+			current.hunks = append(current.hunks, hunk)
+		}
+	}
+	return files, nil
+}
+
+func trimLastNewline(hunk *patchHunk) {
+	if n := len(hunk.lines); n > 0 {
+		hunk.lines[n-1] = strings.TrimSuffix(hunk.lines[n-1], "\n")
+	}
+}
+
+func patchPath(header string) string {
+	if i := strings.IndexByte(header, '\t'); i >= 0 {
+		header = header[:i]
+	}
+	header = strings.TrimSpace(header)
+	if header == "/dev/null" {
+		return header
+	}
+	if strings.HasPrefix(header, "a/") || strings.HasPrefix(header, "b/") {
+		header = header[2:]
+	}
+	return filepath.Clean(header)
+}
+
+func parseHunkHeader(line string) (*patchHunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return nil, fmt.Errorf("invalid hunk header %q", line)
+	}
+	hunk := &patchHunk{}
+	var err error
+	if hunk.oldStart, hunk.oldCount, err = parseHunkRange(fields[1][1:]); err != nil {
+		return nil, err
+	}
+	if hunk.newStart, hunk.newCount, err = parseHunkRange(fields[2][1:]); err != nil {
+		return nil, err
+	}
+	return hunk, nil
+}
+
+func parseHunkRange(value string) (int, int, error) {
+	start, count, found := strings.Cut(value, ",")
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk range %q", value)
+	}
+	if !found {
+		return s, 1, nil
+	}
+	c, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk range %q", value)
+	}
+	return s, c, nil
+}
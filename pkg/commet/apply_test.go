@@ -0,0 +1,116 @@
+package commet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyRejectsUnsafePaths(t *testing.T) {
+	repo := newTestRepo(t)
+	outside := filepath.Join(filepath.Dir(repo.RepoDir), "escaped.txt")
+	for _, path := range []string{"b/../escaped.txt", outside, "b/.commet/HEAD", "b/sub/../../escaped.txt"} {
+		patch := "--- /dev/null\n+++ " + path + "\n@@ -0,0 +1 @@\n+escaped\n"
+		if _, err := repo.Apply(strings.NewReader(patch), ApplyOptions{}); err == nil {
+			t.Errorf("applying a patch to %s succeeded, want an error", path)
+		}
+	}
+	if _, err := os.Stat(outside); err == nil {
+		t.Errorf("patch wrote %s outside the working tree", outside)
+	}
+}
+
+func TestApplyRename(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "old.txt", "one\ntwo\n")
+	patch := "--- a/old.txt\n+++ b/new.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+three\n"
+	applied, err := repo.Apply(strings.NewReader(patch), ApplyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AppliedFile{{Path: "new.txt"}, {Path: "old.txt", Deleted: true}}
+	if len(applied) != len(want) || applied[0] != want[0] || applied[1] != want[1] {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+	if _, err := os.Stat("old.txt"); !os.IsNotExist(err) {
+		t.Errorf("old.txt still exists after the rename: %v", err)
+	}
+	if data, err := os.ReadFile("new.txt"); err != nil || string(data) != "one\nthree\n" {
+		t.Errorf("new.txt = %q, %v; want %q", data, err, "one\nthree\n")
+	}
+
+	writeFile(t, "taken.txt", "taken\n")
+	patch = "--- a/new.txt\n+++ b/taken.txt\n@@ -1,2 +1,2 @@\n one\n-three\n+four\n"
+	if _, err := repo.Apply(strings.NewReader(patch), ApplyOptions{}); err == nil {
+		t.Error("renaming onto an existing file succeeded, want an error")
+	}
+}
+
+func TestApplyRejectsSymlinkedPaths(t *testing.T) {
+	repo := newTestRepo(t)
+	outside := t.TempDir()
+	if err := os.Symlink(outside, "out"); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	if err := os.Symlink(".commet", "meta"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"b/out/escaped.txt", "b/out/sub/escaped.txt", "b/meta/HEAD"} {
+		patch := "--- /dev/null\n+++ " + path + "\n@@ -0,0 +1 @@\n+escaped\n"
+		if _, err := repo.Apply(strings.NewReader(patch), ApplyOptions{}); err == nil {
+			t.Errorf("applying a patch to %s succeeded, want an error", path)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+		t.Errorf("patch wrote %s through a symbolic link", entries[0].Name())
+	}
+}
+
+func TestApplyCheck(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "file.txt", "one\ntwo\n")
+	patch := "--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+three\n" +
+		"--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+new\n"
+	applied, err := repo.Apply(strings.NewReader(patch), ApplyOptions{Check: true})
+	if err != nil || applied != nil {
+		t.Fatalf("Apply with Check = %v, %v; want nothing applied", applied, err)
+	}
+	if data, _ := os.ReadFile("file.txt"); string(data) != "one\ntwo\n" {
+		t.Errorf("file.txt = %q after a check, want it unchanged", data)
+	}
+	if _, err := os.Stat("new.txt"); !os.IsNotExist(err) {
+		t.Errorf("new.txt was created by a check: %v", err)
+	}
+}
+
+func TestApplyReverse(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "file.txt", "one\ntwo\nthree\n")
+	patch := "--- a/file.txt\n+++ b/file.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n"
+	if _, err := repo.Apply(strings.NewReader(patch), ApplyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile("file.txt"); string(data) != "one\nTWO\nthree\n" {
+		t.Fatalf("file.txt = %q after applying", data)
+	}
+	if _, err := repo.Apply(strings.NewReader(patch), ApplyOptions{Reverse: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile("file.txt"); string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("file.txt = %q after reversing, want the original", data)
+	}
+}
+
+func TestApplyFailingHunk(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "file.txt", "one\ntwo\nthree\n")
+	patch := "--- a/file.txt\n+++ b/file.txt\n@@ -2,2 +2,2 @@\n-missing\n+replaced\n three\n"
+	_, err := repo.Apply(strings.NewReader(patch), ApplyOptions{})
+	if err == nil || !strings.Contains(err.Error(), "file.txt:2") {
+		t.Errorf("Apply = %v, want an error naming file.txt:2", err)
+	}
+	if data, _ := os.ReadFile("file.txt"); string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("file.txt = %q after a failed apply, want it unchanged", data)
+	}
+}
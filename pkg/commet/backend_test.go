@@ -0,0 +1,114 @@
+package commet
+
+import (
+	"strings"
+	"testing"
+)
+
+// repoBackends holds a constructor for a fresh repository of each kind, with
+// the working directory set to an empty working tree.
+var repoBackends = []struct {
+	name string
+	new  func(t *testing.T) *Repo
+}{
+	{"disk", newTestRepo},
+	{"memory", func(t *testing.T) *Repo {
+		t.Chdir(t.TempDir())
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		return NewMemRepo()
+	}},
+}
+
+func checkStatus(t *testing.T, repo *Repo, staged, modified, untracked string) {
+	t.Helper()
+	report, err := repo.Status(false, "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{strings.Join(report.Staged, ","), strings.Join(report.Modified, ","), strings.Join(report.Untracked, ",")}
+	want := []string{staged, modified, untracked}
+	for i, kind := range []string{"staged", "modified", "untracked"} {
+		if got[i] != want[i] {
+			t.Errorf("%s = %q, want %q", kind, got[i], want[i])
+		}
+	}
+}
+
+func TestBackendScenario(t *testing.T) {
+	for _, backend := range repoBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			repo := backend.new(t)
+			writeFile(t, "a.txt", "a")
+			writeFile(t, "b.txt", "b")
+			checkStatus(t, repo, "", "", "a.txt,b.txt")
+
+			if err := repo.Add("a.txt", false); err != nil {
+				t.Fatal(err)
+			}
+			checkStatus(t, repo, "a.txt", "", "b.txt")
+			writeFile(t, "a.txt", "changed")
+			checkStatus(t, repo, "a.txt", "a.txt", "b.txt")
+			if err := repo.Add("a.txt", false); err != nil {
+				t.Fatal(err)
+			}
+
+			hash, err := repo.Commit("add a", CommitOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if head, err := repo.Head(); err != nil || head != hash {
+				t.Errorf("Head() = %q, %v; want %s", head, err, hash)
+			}
+			commit, err := repo.ReadCommit(hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			blob, _ := repo.HashFile("a.txt")
+			if strings.Join(commit.Files, ",") != "a.txt" || commit.Blobs["a.txt"] != blob {
+				t.Errorf("commit records files %q and blobs %v, want a.txt at %s", commit.Files, commit.Blobs, blob)
+			}
+			checkStatus(t, repo, "", "", "b.txt")
+			if _, err := repo.Commit("empty", CommitOptions{}); err == nil || err.Error() != "no changes to commit" {
+				t.Errorf("committing with nothing staged: got %v, want 'no changes to commit'", err)
+			}
+
+			if err := repo.Add("b.txt", false); err != nil {
+				t.Fatal(err)
+			}
+			unstaged, err := repo.Reset([]string{"b.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(unstaged, ",") != "b.txt" {
+				t.Errorf("Reset(b.txt) = %q, want [b.txt]", unstaged)
+			}
+			checkStatus(t, repo, "", "", "b.txt")
+		})
+	}
+}
+
+func TestMemRepoSkipsCommetDir(t *testing.T) {
+	disk := newTestRepo(t)
+	writeFile(t, "a.txt", "a")
+	if err := disk.Add("a.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := disk.Commit("on disk", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewMemRepo()
+	untracked, err := repo.UntrackedFiles("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(untracked, ",") != "a.txt" {
+		t.Errorf("UntrackedFiles(all) = %q, want [a.txt]", untracked)
+	}
+	cleaned, err := repo.Clean(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cleaned, ",") != "a.txt" {
+		t.Errorf("Clean(dry run) = %q, want [a.txt]", cleaned)
+	}
+}
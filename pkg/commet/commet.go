@@ -0,0 +1,1764 @@
+// Package commet implements a small Git-like version control system. The
+// commet command in the module root is a thin CLI over it.
+package commet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type Commit struct {
+	Hash      string   `json:"hash"`
+	Parent    string   `json:"parent,omitempty"`
+	Author    string   `json:"author,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	Message   string   `json:"message"`
+	Timestamp string   `json:"timestamp"`
+	Files     []string `json:"files"`
+
+	// Blobs maps each path in Files to the hash of its staged content.
+	// Commits made before blob hashes were recorded have none.
+	Blobs map[string]string `json:"blobs,omitempty"`
+
+	// Modes holds the mode of each executable path in Files. Every other
+	// file has regularMode.
+	Modes map[string]string `json:"modes,omitempty"`
+
+	Committer          string `json:"committer,omitempty"`
+	CommitterEmail     string `json:"committer_email,omitempty"`
+	CommitterTimestamp string `json:"committer_timestamp,omitempty"`
+}
+
+func (c *Commit) Canonical() []byte {
+	var b strings.Builder
+	if c.Parent != "" {
+		fmt.Fprintf(&b, "parent %s\n", c.Parent)
+	}
+	if c.Author != "" || c.Email != "" {
+		fmt.Fprintf(&b, "author %s <%s>\n", c.Author, c.Email)
+	}
+	fmt.Fprintf(&b, "timestamp %s\n", c.Timestamp)
+	if c.Committer != "" || c.CommitterEmail != "" || c.CommitterTimestamp != "" {
+		fmt.Fprintf(&b, "committer %s <%s> %s\n", c.Committer, c.CommitterEmail, c.CommitterTimestamp)
+	}
+	files := append([]string(nil), c.Files...)
+	sort.Strings(files)
+	for _, file := range files {
+		if blob, mode := c.Blobs[file], c.Modes[file]; blob != "" && mode != "" {
+			fmt.Fprintf(&b, "file %s %s %s\n", QuotePath(file), blob, mode)
+		} else if blob != "" {
+			fmt.Fprintf(&b, "file %s %s\n", QuotePath(file), blob)
+		} else {
+			fmt.Fprintf(&b, "file %s\n", QuotePath(file))
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(c.Message)
+	return []byte(b.String())
+}
+
+func (c *Commit) ComputeHash() string {
+	sum := sha1.Sum(c.Canonical())
+	return hex.EncodeToString(sum[:])
+}
+
+type CommitOptions struct {
+	Author   string
+	Date     string
+	Signoff  bool
+	Only     []string
+	Fixup    string
+	Squash   string
+	NoVerify bool
+
+	// All commits the working copy of every tracked file that changed
+	// since HEAD, as well as what is staged. Deleted files stay tracked.
+	All bool
+}
+
+type Config struct {
+	Settings map[string]string `json:"settings"`
+	Remotes  map[string]string `json:"remotes,omitempty"`
+}
+
+// Repo is a repository rooted at RepoDir. Blobs and commits are kept in
+// Objects and Commits, keyed by hash. State holds HEAD, the index, the local
+// config and other refs, keyed by their file name under .commet.
+type Repo struct {
+	RepoDir string
+	VcsDir  string
+	Objects Storage
+	Commits Storage
+	State   Storage
+}
+
+func NewRepo(repoDir string) *Repo {
+	vcsDir := filepath.Join(repoDir, ".commet")
+	return &Repo{
+		RepoDir: repoDir,
+		VcsDir:  vcsDir,
+		Objects: &FileStorage{Dir: filepath.Join(vcsDir, "objects")},
+		Commits: &FileStorage{Dir: filepath.Join(vcsDir, "commits")},
+		State:   &FileStorage{Dir: vcsDir},
+	}
+}
+
+// NewMemRepo returns an initialized repository whose objects, commits, refs,
+// index and local config live in memory. Files are still added from the
+// working tree at RepoDir, and hooks are never run.
+func NewMemRepo() *Repo {
+	return &Repo{
+		RepoDir: "./",
+		Objects: NewMemStorage(),
+		Commits: NewMemStorage(),
+		State:   NewMemStorage(),
+	}
+}
+
+func (r *Repo) inMemory() bool {
+	return r.VcsDir == ""
+}
+
+// Exists reports whether the repository has been initialized.
+func (r *Repo) Exists() bool {
+	if r.inMemory() {
+		return true
+	}
+	info, err := os.Stat(r.VcsDir)
+	return err == nil && info.IsDir()
+}
+
+// isVcsDir reports whether a directory met while walking the working tree
+// holds repository data. It goes by name, so an in-memory repository opened
+// over an existing one still skips its .commet directory.
+func isVcsDir(d os.DirEntry) bool {
+	return d.IsDir() && d.Name() == ".commet"
+}
+
+func (r *Repo) Init() error {
+	if r.inMemory() {
+		return fmt.Errorf("repository already initialized")
+	}
+	if _, err := os.Stat(r.VcsDir); !os.IsNotExist(err) {
+		return fmt.Errorf("repository already initialized")
+	}
+	if err := os.Mkdir(r.VcsDir, 0755); err != nil {
+		return fmt.Errorf("failed to initialize repository: %v", err)
+	}
+	if r.detectIgnoreCase() {
+		if err := r.SetConfig("core.ignorecase", "true"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func globalConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "commet")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "commet")
+}
+
+func readConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return parseConfig(path, data)
+}
+
+func parseConfig(path string, data []byte) (*Config, error) {
+	config := &Config{Settings: map[string]string{}, Remotes: map[string]string{}}
+	if len(data) == 0 {
+		return config, nil
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+	if config.Settings == nil {
+		config.Settings = map[string]string{}
+	}
+	if config.Remotes == nil {
+		config.Remotes = map[string]string{}
+	}
+	return config, nil
+}
+
+func (r *Repo) LoadConfig() (*Config, error) {
+	data, err := r.State.Get("config.json")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return parseConfig("config.json", data)
+}
+
+func LoadGlobalConfig() (*Config, error) {
+	dir := globalConfigDir()
+	if dir == "" {
+		return &Config{Settings: map[string]string{}, Remotes: map[string]string{}}, nil
+	}
+	return readConfigFile(filepath.Join(dir, "config.json"))
+}
+
+func (r *Repo) EffectiveConfig() (*Config, error) {
+	config, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	local, err := r.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range local.Settings {
+		config.Settings[key] = value
+	}
+	for name, url := range local.Remotes {
+		config.Remotes[name] = url
+	}
+	return config, nil
+}
+
+func writeConfigFile(path string, config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (r *Repo) SaveConfig(config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return r.State.Put("config.json", data)
+}
+
+func SaveGlobalConfig(config *Config) error {
+	dir := globalConfigDir()
+	if dir == "" {
+		return fmt.Errorf("cannot locate the user config directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeConfigFile(filepath.Join(dir, "config.json"), config)
+}
+
+func (r *Repo) loadConfigScope(scope string) (*Config, error) {
+	switch scope {
+	case "local":
+		return r.LoadConfig()
+	case "global":
+		return LoadGlobalConfig()
+	default:
+		return r.EffectiveConfig()
+	}
+}
+
+func (r *Repo) saveConfigScope(scope string, config *Config) error {
+	if scope == "global" {
+		return SaveGlobalConfig(config)
+	}
+	return r.SaveConfig(config)
+}
+
+func (r *Repo) ListConfig(scope string) (map[string]string, error) {
+	config, err := r.loadConfigScope(scope)
+	if err != nil {
+		return nil, err
+	}
+	return config.Settings, nil
+}
+
+func (r *Repo) UnsetConfig(scope, key string) error {
+	if scope == "" {
+		scope = "local"
+	}
+	config, err := r.loadConfigScope(scope)
+	if err != nil {
+		return err
+	}
+	if _, ok := config.Settings[key]; !ok {
+		return nil
+	}
+	delete(config.Settings, key)
+	return r.saveConfigScope(scope, config)
+}
+
+func (r *Repo) GetConfig(key string) (string, error) {
+	config, err := r.EffectiveConfig()
+	if err != nil {
+		return "", err
+	}
+	return config.Settings[key], nil
+}
+
+func (r *Repo) SetConfig(key, value string) error {
+	return r.SetConfigScope("local", key, value)
+}
+
+func (r *Repo) SetConfigScope(scope, key, value string) error {
+	if scope == "" {
+		scope = "local"
+	}
+	config, err := r.loadConfigScope(scope)
+	if err != nil {
+		return err
+	}
+	config.Settings[key] = value
+	return r.saveConfigScope(scope, config)
+}
+
+func (r *Repo) AddRemote(name, url string) error {
+	if name == "" || strings.ContainsAny(name, " \t/") {
+		return fmt.Errorf("invalid remote name %q", name)
+	}
+	config, err := r.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := config.Remotes[name]; ok {
+		return fmt.Errorf("remote %s already exists", name)
+	}
+	config.Remotes[name] = url
+	return r.SaveConfig(config)
+}
+
+// RemoveRemote removes name from every config that defines it, so a remote
+// that Remotes lists is gone afterwards whether it was local or global.
+func (r *Repo) RemoveRemote(name string) error {
+	removed := false
+	for _, scope := range []string{"local", "global"} {
+		config, err := r.loadConfigScope(scope)
+		if err != nil {
+			return err
+		}
+		if _, ok := config.Remotes[name]; !ok {
+			continue
+		}
+		delete(config.Remotes, name)
+		if err := r.saveConfigScope(scope, config); err != nil {
+			return err
+		}
+		removed = true
+	}
+	if !removed {
+		return fmt.Errorf("no such remote: %s", name)
+	}
+	return nil
+}
+
+func (r *Repo) Remotes() (map[string]string, error) {
+	config, err := r.EffectiveConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.Remotes, nil
+}
+
+func (r *Repo) detectIgnoreCase() bool {
+	probe := filepath.Join(r.VcsDir, "CaseProbe")
+	if err := os.WriteFile(probe, nil, 0644); err != nil {
+		return false
+	}
+	defer os.Remove(probe)
+	_, err := os.Stat(filepath.Join(r.VcsDir, "caseprobe"))
+	return err == nil
+}
+
+func (r *Repo) ignoreCase() (bool, error) {
+	value, err := r.GetConfig("core.ignorecase")
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *Repo) maxBlobSize() (int64, error) {
+	value, err := r.GetConfig("core.maxBlobSize")
+	if err != nil || value == "" {
+		return 0, err
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid core.maxBlobSize %q: must be a number of bytes", value)
+	}
+	return limit, nil
+}
+
+func (r *Repo) Head() (string, error) {
+	data, err := r.State.Get("HEAD")
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (r *Repo) setHead(hash string) error {
+	return r.State.Put("HEAD", []byte(hash+"\n"))
+}
+
+func (r *Repo) ReadCommit(hash string) (*Commit, error) {
+	data, err := r.Commits.Get(hash)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("commit %s not found", hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var commit Commit
+	if err := json.Unmarshal(data, &commit); err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+	// The storage key names the commit; a body written by hand or by an
+	// older version may omit the hash or carry a stale one.
+	commit.Hash = hash
+	return &commit, nil
+}
+
+func (r *Repo) writeCommit(commit *Commit) error {
+	commit.Hash = commit.ComputeHash()
+	commitData, err := json.Marshal(commit)
+	if err != nil {
+		return err
+	}
+	return r.Commits.Put(commit.Hash, commitData)
+}
+
+func (r *Repo) ResolveHash(prefix string) (string, error) {
+	match, err := resolvePrefix(r.Commits, prefix)
+	if err != nil {
+		return "", err
+	}
+	if match == "" {
+		return "", fmt.Errorf("unknown commit %q", prefix)
+	}
+	return match, nil
+}
+
+// resolvePrefix returns the only key in store starting with prefix, or ""
+// if there is none.
+func resolvePrefix(store Storage, prefix string) (string, error) {
+	if len(prefix) < 4 {
+		return "", fmt.Errorf("hash prefix %q is too short (need at least 4 characters)", prefix)
+	}
+	hashes, err := store.List()
+	if err != nil {
+		return "", err
+	}
+	match := ""
+	for _, hash := range hashes {
+		if !strings.HasPrefix(hash, prefix) {
+			continue
+		}
+		if match != "" {
+			return "", fmt.Errorf("ambiguous hash prefix %q", prefix)
+		}
+		match = hash
+	}
+	return match, nil
+}
+
+func (r *Repo) RevParse(rev string) (string, error) {
+	name := rev
+	suffix := ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		name, suffix = rev[:i], rev[i:]
+	}
+	if name == "" {
+		return "", fmt.Errorf("invalid revision %q", rev)
+	}
+	var hash string
+	var err error
+	if name == "HEAD" {
+		hash, err = r.Head()
+		if err == nil && hash == "" {
+			return "", fmt.Errorf("HEAD does not point to a commit yet")
+		}
+	} else {
+		hash, err = r.ResolveHash(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	for suffix != "" {
+		count := 1
+		end := 1
+		for end < len(suffix) && suffix[end] >= '0' && suffix[end] <= '9' {
+			end++
+		}
+		if end > 1 {
+			if count, err = strconv.Atoi(suffix[1:end]); err != nil {
+				return "", fmt.Errorf("invalid revision %q", rev)
+			}
+		}
+		if end < len(suffix) && suffix[end] != '~' && suffix[end] != '^' {
+			return "", fmt.Errorf("invalid revision %q", rev)
+		}
+		if suffix[0] == '^' {
+			if count > 1 {
+				return "", fmt.Errorf("revision %q: commit %s has no parent %d", rev, hash, count)
+			}
+			if count == 0 {
+				suffix = suffix[end:]
+				continue
+			}
+		}
+		for ; count > 0; count-- {
+			commit, err := r.ReadCommit(hash)
+			if err != nil {
+				return "", err
+			}
+			if commit.Parent == "" {
+				return "", fmt.Errorf("revision %q goes past the first commit", rev)
+			}
+			hash = commit.Parent
+		}
+		suffix = suffix[end:]
+	}
+	return hash, nil
+}
+
+const mmapHashThreshold = 64 << 20
+
+var hashBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 64*1024)
+		return &buf
+	},
+}
+
+func (r *Repo) HashFile(filepath string) (string, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() >= mmapHashThreshold {
+		if hash, ok := hashFileMmap(file, info.Size()); ok {
+			return hash, nil
+		}
+	}
+	return hashStream(file)
+}
+
+func hashStream(content io.Reader) (string, error) {
+	hasher := sha1.New()
+	buf := hashBufferPool.Get().(*[]byte)
+	defer hashBufferPool.Put(buf)
+	if _, err := io.CopyBuffer(hasher, content, *buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func (r *Repo) HashObject(path string, write bool) (string, error) {
+	if !write {
+		return r.HashFile(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return r.HashObjectReader(file, true)
+}
+
+func (r *Repo) HashObjectReader(content io.Reader, write bool) (string, error) {
+	hasher := sha1.New()
+	if !write {
+		if _, err := io.Copy(hasher, content); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	hasher.Write(data)
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if r.Objects.Has(hash) {
+		return hash, nil
+	}
+	if err := r.Objects.Put(hash, data); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+func (r *Repo) Add(filePath string, force bool) error {
+	_, err := r.AddFiles([]string{filePath}, force, nil)
+	return err
+}
+
+// AddFiles stages paths, expanding directories, and returns the files it
+// staged. If progress is not nil it is called after each file with the
+// number staged so far and the total.
+func (r *Repo) AddFiles(paths []string, force bool, progress func(done, total int)) ([]string, error) {
+	return r.AddFilesContext(context.Background(), paths, force, progress)
+}
+
+// AddFilesContext is AddFiles with cancellation checked between files. A
+// cancelled add leaves the staging area as it was.
+func (r *Repo) AddFilesContext(ctx context.Context, paths []string, force bool, progress func(done, total int)) ([]string, error) {
+	files, err := r.expandPaths(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	limit := int64(0)
+	if !force {
+		if limit, err = r.maxBlobSize(); err != nil {
+			return nil, err
+		}
+	}
+	ignoreCase, err := r.ignoreCase()
+	if err != nil {
+		return nil, err
+	}
+	staged, err := r.readStaged()
+	if err != nil {
+		return nil, err
+	}
+	for i, filePath := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rel, err := r.stagedPath(filePath)
+		if err != nil {
+			return nil, err
+		}
+		fileHash, mode, err := r.hashForAdd(filePath, limit)
+		if err != nil {
+			return nil, err
+		}
+		fileData := stagedEntry(rel, fileHash, mode)
+		replaced := false
+		for i, entry := range staged {
+			// Keep the spelling the path was first staged under, so that
+			// re-adding it under another case only refreshes the content.
+			if pathKey(entry["path"], ignoreCase) == pathKey(fileData["path"], ignoreCase) {
+				fileData["path"] = entry["path"]
+				staged[i] = fileData
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			staged = append(staged, fileData)
+		}
+		if progress != nil {
+			progress(i+1, len(files))
+		}
+	}
+	if err := r.writeStaged(staged); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+const (
+	regularMode    = "100644"
+	executableMode = "100755"
+)
+
+// stagedEntry builds an index entry. Only executable files record a mode.
+func stagedEntry(path, hash, mode string) map[string]string {
+	entry := map[string]string{"path": path, "hash": hash}
+	if mode != "" && mode != regularMode {
+		entry["mode"] = mode
+	}
+	return entry
+}
+
+// hashForAdd hashes a file about to be staged and returns its mode, which is
+// executableMode if any executable bit is set and "" otherwise.
+func (r *Repo) hashForAdd(filePath string, limit int64) (string, string, error) {
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return "", "", fmt.Errorf("cannot add %q: no such file: %w", filePath, err)
+	}
+	if os.IsPermission(err) {
+		return "", "", fmt.Errorf("cannot add %q: permission denied: %w", filePath, err)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("cannot add %q: %w", filePath, err)
+	}
+	if info.IsDir() {
+		return "", "", fmt.Errorf("cannot add %q: is a directory", filePath)
+	}
+	if limit > 0 && info.Size() > limit {
+		return "", "", fmt.Errorf("refusing to add %s: file is %d bytes, over the core.maxBlobSize limit of %d bytes\n"+
+			"Add it to .commetignore, or use 'commet add --force %s' to stage it anyway.", filePath, info.Size(), limit, filePath)
+	}
+	fileHash, err := r.HashFile(filePath)
+	if os.IsPermission(err) {
+		return "", "", fmt.Errorf("cannot add %q: permission denied: %w", filePath, err)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("cannot add %q: %w", filePath, err)
+	}
+	mode := ""
+	if info.Mode().Perm()&0111 != 0 {
+		mode = executableMode
+	}
+	return fileHash, mode, nil
+}
+
+func (r *Repo) expandPaths(ctx context.Context, paths []string) ([]string, error) {
+	var matcher *ignoreMatcher
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		if matcher == nil {
+			if matcher, err = r.loadIgnore(); err != nil {
+				return nil, err
+			}
+		}
+		err = filepath.WalkDir(path, func(walked string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rel, err := r.relPath(walked)
+			if err != nil || rel == "." {
+				return err
+			}
+			if d.IsDir() {
+				if isVcsDir(d) || matcher.Match(rel, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !matcher.Match(rel, false) {
+				files = append(files, walked)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// relPath returns path, given relative to the current directory, relative to
+// the working tree.
+func (r *Repo) relPath(path string) (string, error) {
+	root, err := filepath.Abs(r.RepoDir)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(root, abs)
+}
+
+// stagedPath returns the index path for path, which is relative to the
+// current directory. Index paths are relative to the working tree, so that
+// they do not depend on where the caller runs from.
+func (r *Repo) stagedPath(path string) (string, error) {
+	rel, err := r.relPath(path)
+	if err != nil {
+		return "", err
+	}
+	if isOutside(rel) {
+		return "", fmt.Errorf("%q is outside the repository at %s", path, r.RepoDir)
+	}
+	return rel, nil
+}
+
+func parseAuthor(author string) (string, string, error) {
+	open := strings.Index(author, "<")
+	if open < 0 || !strings.HasSuffix(author, ">") || strings.Count(author, "<") != 1 || strings.Count(author, ">") != 1 {
+		return "", "", fmt.Errorf("invalid author %q: expected \"Name <email>\"", author)
+	}
+	name := strings.TrimSpace(author[:open])
+	email := strings.TrimSpace(author[open+1 : len(author)-1])
+	if name == "" || email == "" {
+		return "", "", fmt.Errorf("invalid author %q: expected \"Name <email>\"", author)
+	}
+	return name, email, nil
+}
+
+var commitDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+}
+
+func parseCommitDate(value string) (time.Time, error) {
+	for _, layout := range commitDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: expected RFC 3339 (e.g. 2006-01-02T15:04:05Z07:00)", value)
+}
+
+// commitTimestamp picks the commit time: an explicit --date wins, then
+// SOURCE_DATE_EPOCH, then the current time.
+func commitTimestamp(date string) (time.Time, error) {
+	if date != "" {
+		return parseCommitDate(date)
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil || seconds < 0 {
+			return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: must be a non-negative Unix timestamp", epoch)
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Now(), nil
+}
+
+func (r *Repo) authorIdentity(override string) (string, string, error) {
+	if override != "" {
+		return parseAuthor(override)
+	}
+	name, err := r.GetConfig("user.name")
+	if err != nil {
+		return "", "", err
+	}
+	email, err := r.GetConfig("user.email")
+	if err != nil {
+		return "", "", err
+	}
+	return name, email, nil
+}
+
+func (r *Repo) stampCommitter(commit *Commit) error {
+	name, email, err := r.authorIdentity("")
+	if err != nil {
+		return err
+	}
+	timestamp, err := commitTimestamp("")
+	if err != nil {
+		return err
+	}
+	commit.Committer = name
+	commit.CommitterEmail = email
+	commit.CommitterTimestamp = timestamp.UTC().Format(time.RFC3339)
+	return nil
+}
+
+func (r *Repo) selectOnly(staged []map[string]string, paths []string) ([]map[string]string, []map[string]string, error) {
+	ignoreCase, err := r.ignoreCase()
+	if err != nil {
+		return nil, nil, err
+	}
+	limit, err := r.maxBlobSize()
+	if err != nil {
+		return nil, nil, err
+	}
+	index := map[string]map[string]string{}
+	for _, entry := range staged {
+		index[pathKey(entry["path"], ignoreCase)] = entry
+	}
+	selected := map[string]bool{}
+	var committed []map[string]string
+	for _, path := range paths {
+		rel, err := r.stagedPath(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		key := pathKey(rel, ignoreCase)
+		if selected[key] {
+			continue
+		}
+		entry, isStaged := index[key]
+		if _, err := os.Stat(path); err == nil {
+			hash, mode, err := r.hashForAdd(path, limit)
+			if err != nil {
+				return nil, nil, err
+			}
+			entry = stagedEntry(rel, hash, mode)
+		} else if !isStaged {
+			return nil, nil, fmt.Errorf("pathspec %q is neither staged nor present in the working tree", path)
+		}
+		selected[key] = true
+		committed = append(committed, entry)
+	}
+	var remaining []map[string]string
+	for _, entry := range staged {
+		if !selected[pathKey(entry["path"], ignoreCase)] {
+			remaining = append(remaining, entry)
+		}
+	}
+	return committed, remaining, nil
+}
+
+// autosquashMessage builds the "fixup! " or "squash! " subject that a later
+// autosquash uses to find the commit to fold into.
+func (r *Repo) autosquashMessage(message string, opts CommitOptions) (string, error) {
+	prefix, rev := "fixup! ", opts.Fixup
+	if opts.Squash != "" {
+		if opts.Fixup != "" {
+			return "", fmt.Errorf("cannot use --fixup and --squash together")
+		}
+		prefix, rev = "squash! ", opts.Squash
+	}
+	if rev == "" {
+		return message, nil
+	}
+	if opts.Fixup != "" && message != "" {
+		return "", fmt.Errorf("cannot combine --fixup with a commit message")
+	}
+	hash, err := r.RevParse(rev)
+	if err != nil {
+		return "", err
+	}
+	target, err := r.ReadCommit(hash)
+	if err != nil {
+		return "", err
+	}
+	subject, _, _ := strings.Cut(target.Message, "\n")
+	if message != "" {
+		return prefix + subject + "\n\n" + message, nil
+	}
+	return prefix + subject, nil
+}
+
+// stageTracked returns staged with the working copy of each file tracked at
+// HEAD hashed into it, when that copy differs from HEAD or is already
+// staged. Deleted files are skipped, since the index cannot record a
+// removal. The index itself is not written, so a commit that fails leaves
+// it as it was.
+func (r *Repo) stageTracked(staged []map[string]string) ([]map[string]string, error) {
+	head, err := r.Head()
+	if err != nil || head == "" {
+		return staged, err
+	}
+	tree, err := r.LsTree(head, true)
+	if err != nil {
+		return nil, err
+	}
+	ignoreCase, err := r.ignoreCase()
+	if err != nil {
+		return nil, err
+	}
+	limit, err := r.maxBlobSize()
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, entry := range staged {
+		index[pathKey(entry["path"], ignoreCase)] = i
+	}
+	for _, entry := range tree {
+		path := filepath.Join(r.RepoDir, entry.Path)
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+		hash, mode, err := r.hashForAdd(path, limit)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[pathKey(entry.Path, ignoreCase)]; ok {
+			staged[i] = stagedEntry(staged[i]["path"], hash, mode)
+			continue
+		}
+		if mode == "" {
+			mode = regularMode
+		}
+		if hash != entry.Hash || mode != entry.Mode {
+			staged = append(staged, stagedEntry(entry.Path, hash, mode))
+		}
+	}
+	return staged, nil
+}
+
+func (r *Repo) Commit(message string, opts CommitOptions) (string, error) {
+	staged, err := r.readStaged()
+	if err != nil {
+		return "", err
+	}
+	if opts.All {
+		if len(opts.Only) > 0 {
+			return "", fmt.Errorf("cannot combine --all with --only")
+		}
+		if staged, err = r.stageTracked(staged); err != nil {
+			return "", err
+		}
+	}
+	if len(staged) == 0 && len(opts.Only) == 0 {
+		return "", fmt.Errorf("no changes to commit")
+	}
+	var remaining []map[string]string
+	if len(opts.Only) > 0 {
+		if staged, remaining, err = r.selectOnly(staged, opts.Only); err != nil {
+			return "", err
+		}
+	}
+	if message, err = r.autosquashMessage(message, opts); err != nil {
+		return "", err
+	}
+	author, email, err := r.authorIdentity(opts.Author)
+	if err != nil {
+		return "", err
+	}
+	timestamp, err := commitTimestamp(opts.Date)
+	if err != nil {
+		return "", err
+	}
+	if opts.Signoff {
+		name, email, err := r.authorIdentity("")
+		if err != nil {
+			return "", err
+		}
+		if name == "" || email == "" {
+			return "", fmt.Errorf("cannot sign off: user.name and user.email must be configured")
+		}
+		message = AppendTrailer(message, "Signed-off-by", fmt.Sprintf("%s <%s>", name, email))
+	}
+	// The hooks run only once the options are known to be valid, so a
+	// mistyped flag does not trigger a slow pre-commit check.
+	if !opts.NoVerify {
+		if err := r.runHook("pre-commit"); err != nil {
+			return "", err
+		}
+		if message, err = r.runCommitMsgHook(message); err != nil {
+			return "", err
+		}
+	}
+	parent, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+	files := []string{}
+	blobs := map[string]string{}
+	var modes map[string]string
+	for _, entry := range staged {
+		files = append(files, entry["path"])
+		blobs[entry["path"]] = entry["hash"]
+		if mode := entry["mode"]; mode != "" {
+			if modes == nil {
+				modes = map[string]string{}
+			}
+			modes[entry["path"]] = mode
+		}
+	}
+	sort.Strings(files)
+	commit := Commit{
+		Parent:    parent,
+		Author:    author,
+		Email:     email,
+		Message:   message,
+		Timestamp: timestamp.UTC().Format(time.RFC3339),
+		Files:     files,
+		Blobs:     blobs,
+		Modes:     modes,
+	}
+	if err := r.stampCommitter(&commit); err != nil {
+		return "", err
+	}
+	if err := r.writeCommit(&commit); err != nil {
+		return "", err
+	}
+	if err := r.setHead(commit.Hash); err != nil {
+		return "", err
+	}
+	if err := r.writeStaged(remaining); err != nil {
+		return "", err
+	}
+	return commit.Hash, nil
+}
+
+// Reset unstages paths and returns the index entries it removed.
+func (r *Repo) Reset(paths []string) ([]string, error) {
+	staged, err := r.readStaged()
+	if err != nil {
+		return nil, err
+	}
+	ignoreCase, err := r.ignoreCase()
+	if err != nil {
+		return nil, err
+	}
+	unstage := map[string]bool{}
+	for _, path := range paths {
+		rel, err := r.stagedPath(path)
+		if err != nil {
+			return nil, err
+		}
+		unstage[pathKey(rel, ignoreCase)] = true
+	}
+	var remaining []map[string]string
+	var unstaged []string
+	for _, entry := range staged {
+		if unstage[pathKey(entry["path"], ignoreCase)] {
+			unstaged = append(unstaged, entry["path"])
+			continue
+		}
+		remaining = append(remaining, entry)
+	}
+	return unstaged, r.writeStaged(remaining)
+}
+
+// writeStaged replaces the index, removing it when nothing is staged.
+func (r *Repo) writeStaged(staged []map[string]string) error {
+	if len(staged) == 0 {
+		return r.State.Delete("staged.json")
+	}
+	data, err := json.Marshal(staged)
+	if err != nil {
+		return err
+	}
+	return r.State.Put("staged.json", data)
+}
+
+func (r *Repo) readStaged() ([]map[string]string, error) {
+	data, err := r.State.Get("staged.json")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var staged []map[string]string
+	if err := json.Unmarshal(data, &staged); err != nil {
+		return nil, fmt.Errorf("the staging area is corrupt; run 'commet repair' and re-add your files")
+	}
+	return staged, nil
+}
+
+// StatusReport describes the working tree relative to the staging area.
+// Modified and Deleted are staged paths whose files have changed since they
+// were staged. Ignored is only filled in when requested.
+type StatusReport struct {
+	Staged    []string
+	Modified  []string
+	Deleted   []string
+	Untracked []string
+	Ignored   []string
+}
+
+func (r *Repo) Status(showIgnored bool, untrackedMode string) (*StatusReport, error) {
+	staged, err := r.readStaged()
+	if err != nil {
+		return nil, err
+	}
+	report := &StatusReport{}
+	for _, file := range staged {
+		report.Staged = append(report.Staged, file["path"])
+		hash, err := r.HashFile(filepath.Join(r.RepoDir, file["path"]))
+		if os.IsNotExist(err) {
+			report.Deleted = append(report.Deleted, file["path"])
+		} else if err != nil {
+			return nil, err
+		} else if hash != file["hash"] {
+			report.Modified = append(report.Modified, file["path"])
+		}
+	}
+	if report.Untracked, err = r.UntrackedFiles(untrackedMode); err != nil {
+		return nil, err
+	}
+	if showIgnored {
+		if report.Ignored, err = r.IgnoredFiles(); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// normalizePath returns the NFC form of p. It is only a comparison key: the
+// index keeps the spelling found on disk, which is what opens the file on
+// file systems that do not normalize names.
+func normalizePath(p string) string {
+	return norm.NFC.String(filepath.Clean(p))
+}
+
+func pathKey(p string, ignoreCase bool) string {
+	p = normalizePath(p)
+	if ignoreCase {
+		return strings.ToLower(p)
+	}
+	return p
+}
+
+func QuotePath(path string) string {
+	needsQuote := strings.HasPrefix(path, "-")
+	for i := 0; i < len(path) && !needsQuote; i++ {
+		c := path[i]
+		needsQuote = c == ' ' || c == '"' || c == '\\' || c < 0x20 || c == 0x7f
+	}
+	if !needsQuote {
+		return path
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (r *Repo) trackedFiles(ignoreCase bool) (map[string]bool, error) {
+	tracked := map[string]bool{}
+	staged, err := r.readStaged()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range staged {
+		tracked[pathKey(file["path"], ignoreCase)] = true
+	}
+	hashes, err := r.Commits.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range hashes {
+		commit, err := r.ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range commit.Files {
+			tracked[pathKey(file, ignoreCase)] = true
+		}
+	}
+	return tracked, nil
+}
+
+func (r *Repo) IgnoredFiles() ([]string, error) {
+	ignoreCase, err := r.ignoreCase()
+	if err != nil {
+		return nil, err
+	}
+	tracked, err := r.trackedFiles(ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := r.loadIgnore()
+	if err != nil {
+		return nil, err
+	}
+	var ignored []string
+	err = filepath.WalkDir(r.RepoDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(r.RepoDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() {
+			if isVcsDir(d) {
+				return filepath.SkipDir
+			}
+			if matcher.Match(rel, true) {
+				ignored = append(ignored, rel+"/")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !tracked[pathKey(rel, ignoreCase)] && matcher.Match(rel, false) {
+			ignored = append(ignored, rel)
+		}
+		return nil
+	})
+	return ignored, err
+}
+
+func (r *Repo) untrackedFiles(includeIgnored bool) ([]string, map[string]bool, bool, error) {
+	ignoreCase, err := r.ignoreCase()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	tracked, err := r.trackedFiles(ignoreCase)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	matcher, err := r.loadIgnore()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	var untracked []string
+	err = filepath.WalkDir(r.RepoDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(r.RepoDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() {
+			if isVcsDir(d) || (!includeIgnored && matcher.Match(rel, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if tracked[pathKey(rel, ignoreCase)] || (!includeIgnored && matcher.Match(rel, false)) {
+			return nil
+		}
+		untracked = append(untracked, rel)
+		return nil
+	})
+	return untracked, tracked, ignoreCase, err
+}
+
+func (r *Repo) UntrackedFiles(mode string) ([]string, error) {
+	if mode == "no" {
+		return nil, nil
+	}
+	files, tracked, ignoreCase, err := r.untrackedFiles(false)
+	if err != nil || mode == "all" {
+		return files, err
+	}
+	trackedDirs := map[string]bool{}
+	for key := range tracked {
+		for dir := filepath.Dir(key); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+	var collapsed []string
+	seen := map[string]bool{}
+	for _, file := range files {
+		entry := file
+		parts := strings.Split(filepath.ToSlash(file), "/")
+		for depth := 1; depth < len(parts); depth++ {
+			dir := filepath.FromSlash(strings.Join(parts[:depth], "/"))
+			if !trackedDirs[pathKey(dir, ignoreCase)] {
+				entry = dir + "/"
+				break
+			}
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			collapsed = append(collapsed, entry)
+		}
+	}
+	return collapsed, nil
+}
+
+func (r *Repo) Clean(dryRun, includeIgnored bool) ([]string, error) {
+	files, _, _, err := r.untrackedFiles(includeIgnored)
+	if err != nil || dryRun {
+		return files, err
+	}
+	var removed []string
+	for _, file := range files {
+		if err := os.Remove(filepath.Join(r.RepoDir, file)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, file)
+	}
+	return removed, nil
+}
+
+// Squash replaces the commits in base..tip with a single commit and returns
+// its hash along with the number of commits it replaced.
+func (r *Repo) Squash(base, tip, message string) (string, int, error) {
+	baseHash, err := r.RevParse(base)
+	if err != nil {
+		return "", 0, err
+	}
+	tipHash, err := r.RevParse(tip)
+	if err != nil {
+		return "", 0, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", 0, err
+	}
+	var chain []*Commit
+	tipIndex, baseIndex := -1, -1
+	for hash := head; hash != ""; {
+		commit, err := r.ReadCommit(hash)
+		if err != nil {
+			return "", 0, err
+		}
+		if hash == tipHash {
+			tipIndex = len(chain)
+		}
+		if hash == baseHash {
+			baseIndex = len(chain)
+		}
+		chain = append(chain, commit)
+		hash = commit.Parent
+	}
+	if tipIndex < 0 {
+		return "", 0, fmt.Errorf("cannot squash: %s is not in the history of HEAD", tip)
+	}
+	if baseIndex < 0 || baseIndex <= tipIndex {
+		return "", 0, fmt.Errorf("cannot squash: %s is not an ancestor of %s", base, tip)
+	}
+	squashed := chain[tipIndex:baseIndex]
+	if len(squashed) < 2 {
+		return "", 0, fmt.Errorf("nothing to squash: %s..%s contains %d commit(s)", base, tip, len(squashed))
+	}
+	seen := map[string]bool{}
+	files := []string{}
+	blobs := map[string]string{}
+	var modes map[string]string
+	for _, commit := range squashed {
+		for _, file := range commit.Files {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+				if blob := commit.Blobs[file]; blob != "" {
+					blobs[file] = blob
+				}
+				if mode := commit.Modes[file]; mode != "" {
+					if modes == nil {
+						modes = map[string]string{}
+					}
+					modes[file] = mode
+				}
+			}
+		}
+	}
+	sort.Strings(files)
+	oldest := squashed[len(squashed)-1]
+	replacement := &Commit{
+		Parent:    baseHash,
+		Author:    oldest.Author,
+		Email:     oldest.Email,
+		Message:   message,
+		Timestamp: oldest.Timestamp,
+		Files:     files,
+		Blobs:     blobs,
+		Modes:     modes,
+	}
+	if err := r.stampCommitter(replacement); err != nil {
+		return "", 0, err
+	}
+	if err := r.writeCommit(replacement); err != nil {
+		return "", 0, err
+	}
+	parent := replacement.Hash
+	for i := tipIndex - 1; i >= 0; i-- {
+		rewritten := *chain[i]
+		rewritten.Parent = parent
+		if err := r.stampCommitter(&rewritten); err != nil {
+			return "", 0, err
+		}
+		if err := r.writeCommit(&rewritten); err != nil {
+			return "", 0, err
+		}
+		parent = rewritten.Hash
+	}
+	if err := r.setHead(parent); err != nil {
+		return "", 0, err
+	}
+	return replacement.Hash, len(squashed), nil
+}
+
+// PruneEmpty removes the commits in the history of HEAD that record the same
+// blob for every file as their parent's tree already had, relinks the
+// commits after them, and returns how many it removed. Commits without blob
+// hashes are always kept, since there is nothing to compare them by.
+func (r *Repo) PruneEmpty() (int, error) {
+	head, err := r.Head()
+	if err != nil {
+		return 0, err
+	}
+	var chain []*Commit
+	for hash := head; hash != ""; {
+		commit, err := r.ReadCommit(hash)
+		if err != nil {
+			return 0, err
+		}
+		chain = append(chain, commit)
+		hash = commit.Parent
+	}
+	tree := map[string]string{}
+	pruned := 0
+	parent := ""
+	for i := len(chain) - 1; i >= 0; i-- {
+		commit := chain[i]
+		empty := len(commit.Files) > 0
+		for _, file := range commit.Files {
+			blob := commit.Blobs[file]
+			recorded := blob + " " + commit.Modes[file]
+			if blob == "" || tree[file] != recorded {
+				empty = false
+			}
+			tree[file] = recorded
+		}
+		if empty {
+			pruned++
+			continue
+		}
+		if commit.Parent != parent {
+			rewritten := *commit
+			rewritten.Parent = parent
+			if err := r.stampCommitter(&rewritten); err != nil {
+				return 0, err
+			}
+			if err := r.writeCommit(&rewritten); err != nil {
+				return 0, err
+			}
+			commit = &rewritten
+		}
+		parent = commit.Hash
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+	if err := r.setHead(parent); err != nil {
+		return 0, err
+	}
+	return pruned, nil
+}
+
+func (r *Repo) AheadBehind(local, upstream string) (ahead, behind int, err error) {
+	localHash, err := r.RevParse(local)
+	if err != nil {
+		return 0, 0, err
+	}
+	upstreamHash, err := r.RevParse(upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+	localSet, err := r.ancestors(localHash)
+	if err != nil {
+		return 0, 0, err
+	}
+	upstreamSet, err := r.ancestors(upstreamHash)
+	if err != nil {
+		return 0, 0, err
+	}
+	for hash := range localSet {
+		if !upstreamSet[hash] {
+			ahead++
+		}
+	}
+	for hash := range upstreamSet {
+		if !localSet[hash] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// TreeEntry is a file in a commit's tree, or a subdirectory when Path ends in
+// a slash. Mode is a git-style file mode, 040000 for directories. Hash is the
+// file's blob hash, and is empty for directories and for files last recorded
+// before commits kept blob hashes.
+type TreeEntry struct {
+	Path string
+	Mode string
+	Hash string
+}
+
+// LsTree returns the tree at a commit. Commits only record the paths they
+// touched, so the tree is the union of the commit and its ancestors, and each
+// file's hash comes from the newest commit that recorded it. Unless recursive
+// is set, the files below each top-level directory are listed as that
+// directory instead.
+func (r *Repo) LsTree(rev string, recursive bool) ([]TreeEntry, error) {
+	hash, err := r.RevParse(rev)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var entries []TreeEntry
+	for hash != "" {
+		commit, err := r.ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range commit.Files {
+			entry := TreeEntry{Path: file, Mode: regularMode, Hash: commit.Blobs[file]}
+			if mode := commit.Modes[file]; mode != "" {
+				entry.Mode = mode
+			}
+			if dir, _, nested := strings.Cut(filepath.ToSlash(file), "/"); nested && !recursive {
+				entry = TreeEntry{Path: dir + "/", Mode: "040000"}
+			}
+			if !seen[entry.Path] {
+				seen[entry.Path] = true
+				entries = append(entries, entry)
+			}
+		}
+		hash = commit.Parent
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+func (r *Repo) allCommits() ([]*Commit, error) {
+	hashes, err := r.Commits.List()
+	if err != nil {
+		return nil, err
+	}
+	var commits []*Commit
+	for _, hash := range hashes {
+		commit, err := r.ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+	sort.Slice(commits, func(i, j int) bool {
+		if commits[i].Timestamp != commits[j].Timestamp {
+			return commits[i].Timestamp < commits[j].Timestamp
+		}
+		return commits[i].Hash < commits[j].Hash
+	})
+	return commits, nil
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	return "\"" + strings.ReplaceAll(s, "\n", "\\n") + "\""
+}
+
+func (r *Repo) WriteDOT(w io.Writer) error {
+	commits, err := r.allCommits()
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph commits {")
+	fmt.Fprintln(bw, "  rankdir=BT;")
+	fmt.Fprintln(bw, "  node [shape=ellipse, fontname=\"monospace\"];")
+	for _, commit := range commits {
+		subject, _, _ := strings.Cut(commit.Message, "\n")
+		short := commit.Hash
+		if len(short) > 7 {
+			short = short[:7]
+		}
+		fmt.Fprintf(bw, "  %s [label=%s];\n", dotQuote(commit.Hash), dotQuote(short+"\n"+subject))
+	}
+	for _, commit := range commits {
+		if commit.Parent != "" {
+			fmt.Fprintf(bw, "  %s -> %s;\n", dotQuote(commit.Hash), dotQuote(commit.Parent))
+		}
+	}
+	if head != "" {
+		fmt.Fprintln(bw, "  \"HEAD\" [shape=box, style=filled, fillcolor=lightyellow];")
+		fmt.Fprintf(bw, "  \"HEAD\" -> %s [style=dashed];\n", dotQuote(head))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func (r *Repo) readNotes() (map[string]string, error) {
+	notes := map[string]string{}
+	data, err := r.State.Get("refs/notes/commits")
+	if os.IsNotExist(err) {
+		return notes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &notes); err != nil {
+		return nil, fmt.Errorf("failed to read notes: %v", err)
+	}
+	return notes, nil
+}
+
+func (r *Repo) writeNotes(notes map[string]string) error {
+	data, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return r.State.Put("refs/notes/commits", data)
+}
+
+func (r *Repo) AddNote(rev, text string, force bool) error {
+	hash, err := r.RevParse(rev)
+	if err != nil {
+		return err
+	}
+	notes, err := r.readNotes()
+	if err != nil {
+		return err
+	}
+	if _, ok := notes[hash]; ok && !force {
+		return fmt.Errorf("commit %s already has a note (use -f to overwrite)", hash)
+	}
+	notes[hash] = text
+	return r.writeNotes(notes)
+}
+
+func (r *Repo) Note(rev string) (string, error) {
+	hash, err := r.RevParse(rev)
+	if err != nil {
+		return "", err
+	}
+	notes, err := r.readNotes()
+	if err != nil {
+		return "", err
+	}
+	note, ok := notes[hash]
+	if !ok {
+		return "", fmt.Errorf("no note found for commit %s", hash)
+	}
+	return note, nil
+}
+
+func (r *Repo) RemoveNote(rev string) error {
+	hash, err := r.RevParse(rev)
+	if err != nil {
+		return err
+	}
+	notes, err := r.readNotes()
+	if err != nil {
+		return err
+	}
+	if _, ok := notes[hash]; !ok {
+		return fmt.Errorf("no note found for commit %s", hash)
+	}
+	delete(notes, hash)
+	return r.writeNotes(notes)
+}
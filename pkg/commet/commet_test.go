@@ -0,0 +1,951 @@
+package commet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRepo initializes a repository in a temporary directory and makes
+// that directory the current one, so tests can use short relative paths.
+// The global config is pointed at an empty directory.
+func newTestRepo(t *testing.T) *Repo {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repo := NewRepo(dir)
+	if err := repo.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func stagedPaths(t *testing.T, repo *Repo) []string {
+	t.Helper()
+	staged, err := repo.readStaged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, entry := range staged {
+		paths = append(paths, entry["path"])
+	}
+	return paths
+}
+
+func TestAddMaxBlobSize(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.SetConfig("core.maxBlobSize", "10"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "limit.bin", strings.Repeat("x", 10))
+	writeFile(t, "over.bin", strings.Repeat("x", 11))
+
+	if err := repo.Add("limit.bin", false); err != nil {
+		t.Errorf("adding a file of exactly core.maxBlobSize bytes: %v", err)
+	}
+	err := repo.Add("over.bin", false)
+	if err == nil || !strings.Contains(err.Error(), "core.maxBlobSize") {
+		t.Errorf("adding a file one byte over core.maxBlobSize: got %v, want a core.maxBlobSize error", err)
+	}
+	if got := stagedPaths(t, repo); len(got) != 1 || got[0] != "limit.bin" {
+		t.Errorf("staged = %q, want [limit.bin]", got)
+	}
+	if err := repo.Add("over.bin", true); err != nil {
+		t.Errorf("adding an oversized file with force: %v", err)
+	}
+}
+
+func TestAddMissingFile(t *testing.T) {
+	repo := newTestRepo(t)
+	err := repo.Add("missing.txt", false)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("adding a missing file: got %v, want an error wrapping fs.ErrNotExist", err)
+	}
+}
+
+func TestAddUnreadableFile(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("file permissions are not enforced for root")
+	}
+	repo := newTestRepo(t)
+	writeFile(t, "secret.txt", "secret")
+	if err := os.Chmod("secret.txt", 0); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.Add("secret.txt", false)
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("adding an unreadable file: got %v, want an error wrapping fs.ErrPermission", err)
+	}
+	if got := stagedPaths(t, repo); len(got) != 0 {
+		t.Errorf("staged = %q after a failed add, want nothing", got)
+	}
+}
+
+func TestAddUnicodeNormalization(t *testing.T) {
+	const nfc, nfd = "Caf\u00e9.txt", "Cafe\u0301.txt"
+	if pathKey(nfc, false) != pathKey(nfd, false) {
+		t.Fatalf("pathKey(%q) != pathKey(%q)", nfc, nfd)
+	}
+	repo := newTestRepo(t)
+	writeFile(t, nfd, "decomposed")
+	writeFile(t, nfc, "composed")
+	if err := repo.Add(nfd, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Add(nfc, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := stagedPaths(t, repo); len(got) != 1 {
+		t.Errorf("staged = %q, want one entry for both spellings", got)
+	}
+	unstaged, err := repo.Reset([]string{nfd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unstaged) != 1 {
+		t.Errorf("reset %q unstaged %q, want the single entry", nfd, unstaged)
+	}
+}
+
+func TestAddIgnoreCase(t *testing.T) {
+	for _, tc := range []struct {
+		ignoreCase string
+		want       []string
+	}{
+		{"false", []string{"README.txt", "readme.txt"}},
+		{"true", []string{"README.txt"}},
+	} {
+		t.Run("ignorecase="+tc.ignoreCase, func(t *testing.T) {
+			repo := newTestRepo(t)
+			if err := repo.SetConfig("core.ignorecase", tc.ignoreCase); err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, "README.txt", "upper")
+			if _, err := os.Stat("readme.txt"); err == nil {
+				t.Skip("the file system is case-insensitive")
+			}
+			writeFile(t, "readme.txt", "lower")
+			if _, err := repo.AddFiles([]string{"README.txt", "readme.txt"}, false, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := stagedPaths(t, repo); !slices.Equal(got, tc.want) {
+				t.Errorf("staged = %q, want %q", got, tc.want)
+			}
+			if tc.ignoreCase == "true" {
+				hash, err := repo.HashFile("readme.txt")
+				if err != nil {
+					t.Fatal(err)
+				}
+				staged, err := repo.readStaged()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if staged[0]["hash"] != hash {
+					t.Errorf("README.txt is staged with %s, want the re-added content %s", staged[0]["hash"], hash)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusDecomposedPath(t *testing.T) {
+	const nfd = "Cafe\u0301.txt"
+	repo := newTestRepo(t)
+	writeFile(t, nfd, "decomposed")
+	if err := repo.Add(nfd, false); err != nil {
+		t.Fatal(err)
+	}
+	report, err := repo.Status(false, "normal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Staged) != 1 || len(report.Deleted) != 0 || len(report.Modified) != 0 {
+		t.Errorf("status after adding %q: staged %q, modified %q, deleted %q; want it staged and unchanged",
+			nfd, report.Staged, report.Modified, report.Deleted)
+	}
+}
+
+func TestCorruptIndex(t *testing.T) {
+	for name, data := range map[string]string{
+		"truncated": `[{"path":"a.txt","hash":"da39a3ee`,
+		"garbage":   "\x00\x01not json",
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := NewMemRepo()
+			if err := repo.State.Put("staged.json", []byte(data)); err != nil {
+				t.Fatal(err)
+			}
+			_, err := repo.Status(false, "no")
+			if err == nil || !strings.Contains(err.Error(), "commet repair") {
+				t.Errorf("status with a %s index: got %v, want an error suggesting 'commet repair'", name, err)
+			}
+			if _, err := repo.Commit("message", CommitOptions{}); err == nil {
+				t.Errorf("commit with a %s index succeeded", name)
+			}
+		})
+	}
+
+	repo := NewMemRepo()
+	if err := repo.State.Put("staged.json", []byte("\n")); err != nil {
+		t.Fatal(err)
+	}
+	if staged, err := repo.readStaged(); err != nil || len(staged) != 0 {
+		t.Errorf("readStaged on an empty index = %v, %v; want nothing staged", staged, err)
+	}
+}
+
+func TestLsTree(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "a.txt", "a")
+	writeFile(t, "src/b.txt", "b")
+	writeFile(t, "run.sh", "#!/bin/sh\n")
+	if err := os.Chmod("run.sh", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddFiles([]string{"a.txt", filepath.Join("src", "b.txt"), "run.sh"}, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit("first", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "a.txt", "changed")
+	if err := repo.Add("a.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit("second", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	hashA, _ := repo.HashFile("a.txt")
+	hashB, _ := repo.HashFile(filepath.Join("src", "b.txt"))
+	hashRun, _ := repo.HashFile("run.sh")
+
+	entries, err := repo.LsTree("HEAD", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TreeEntry{{"a.txt", "100644", hashA}, {"run.sh", "100755", hashRun}, {"src/b.txt", "100644", hashB}}
+	if !slices.Equal(entries, want) {
+		t.Errorf("LsTree(HEAD, true) = %v, want %v", entries, want)
+	}
+	entries, err = repo.LsTree("HEAD", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []TreeEntry{{"a.txt", "100644", hashA}, {"run.sh", "100755", hashRun}, {"src/", "040000", ""}}
+	if !slices.Equal(entries, want) {
+		t.Errorf("LsTree(HEAD, false) = %v, want %v", entries, want)
+	}
+
+	if err := os.Chmod("run.sh", 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Add("run.sh", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit("not executable", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err = repo.LsTree("HEAD", true); err != nil {
+		t.Fatal(err)
+	}
+	if entries[1].Mode != "100644" {
+		t.Errorf("run.sh has mode %s after dropping its executable bit, want 100644", entries[1].Mode)
+	}
+}
+
+func TestAddDirectory(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, filepath.Join("src", "a.txt"), "a")
+	writeFile(t, filepath.Join("src", "sub", "b.txt"), "b")
+	writeFile(t, filepath.Join("src", "debug.log"), "log")
+	writeFile(t, ".commetignore", "*.log\n")
+	files, err := repo.AddFiles([]string{"src"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join("src", "a.txt"), filepath.Join("src", "sub", "b.txt")}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("AddFiles(src) = %q, want %q", files, want)
+	}
+}
+
+func TestAddFilesContextCancel(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "kept.txt", "kept")
+	if err := repo.Add("kept.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		writeFile(t, filepath.Join("dir", fmt.Sprintf("file%d.txt", i)), "content")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hashed := 0
+	progress := func(done, total int) {
+		hashed = done
+		if done == 3 {
+			cancel()
+		}
+	}
+	_, err := repo.AddFilesContext(ctx, []string{"dir"}, false, progress)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("AddFilesContext = %v, want context.Canceled", err)
+	}
+	if hashed != 3 {
+		t.Errorf("hashed %d files, want the add to stop after the 3 before the cancel", hashed)
+	}
+	if got := stagedPaths(t, repo); len(got) != 1 || got[0] != "kept.txt" {
+		t.Errorf("staged = %q after a cancelled add, want only kept.txt", got)
+	}
+}
+
+// TestAddFromOutsideWorkingTree drives a repository from a different
+// current directory, so every path is given with the repository prefix.
+func TestAddFromOutsideWorkingTree(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	repo := NewRepo(dir)
+	if err := repo.Init(); err != nil {
+		t.Fatal(err)
+	}
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "b.txt")
+	writeFile(t, a, "a")
+	writeFile(t, b, "b")
+	if _, err := repo.AddFiles([]string{a, filepath.Join(dir, "sub")}, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	report, err := repo.Status(false, "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStaged := "a.txt," + filepath.Join("sub", "b.txt")
+	if got := strings.Join(report.Staged, ","); got != wantStaged || len(report.Deleted) != 0 || len(report.Untracked) != 0 {
+		t.Errorf("status: staged %q, deleted %q, untracked %q; want %s staged only", got, report.Deleted, report.Untracked, wantStaged)
+	}
+	if unstaged, err := repo.Reset([]string{b}); err != nil || len(unstaged) != 1 {
+		t.Errorf("Reset(%s) = %q, %v; want it unstaged", b, unstaged, err)
+	}
+	hash, err := repo.Commit("first", CommitOptions{Only: []string{a}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.ReadCommit(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(commit.Files, ",") != "a.txt" {
+		t.Errorf("commit recorded %q, want [a.txt]", commit.Files)
+	}
+	if err := repo.Add(filepath.Join(t.TempDir(), "elsewhere.txt"), false); err == nil || !strings.Contains(err.Error(), "outside the repository") {
+		t.Errorf("adding a file outside the working tree: got %v, want an 'outside the repository' error", err)
+	}
+}
+
+func TestQuotePath(t *testing.T) {
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{"plain.txt", "plain.txt"},
+		{"dir/sub/file.go", "dir/sub/file.go"},
+		{"café.txt", "café.txt"},
+		{"my file.txt", `"my file.txt"`},
+		{"-rf", `"-rf"`},
+		{"a-b.txt", "a-b.txt"},
+		{"line\nbreak", `"line\nbreak"`},
+		{"tab\there", `"tab\there"`},
+		{"cr\rhere", `"cr\rhere"`},
+		{`quote"d`, `"quote\"d"`},
+		{`back\slash`, `"back\\slash"`},
+		{"bell\x07", `"bell\007"`},
+		{"del\x7f", `"del\177"`},
+	} {
+		if got := QuotePath(tc.path); got != tc.want {
+			t.Errorf("QuotePath(%q) = %s, want %s", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyObject(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "a.txt", "a")
+	if err := repo.Add("a.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.Commit("add a", CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := repo.HashObject("a.txt", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []string{commit, commit[:7], blob, blob[:7]} {
+		if err := repo.VerifyObject(hash); err != nil {
+			t.Errorf("VerifyObject(%s) = %v, want nil", hash, err)
+		}
+	}
+	if err := repo.VerifyObject("0000000"); err == nil || !strings.Contains(err.Error(), "unknown object") {
+		t.Errorf("VerifyObject of a missing object = %v, want an unknown object error", err)
+	}
+
+	if err := repo.Objects.Put(blob, []byte("tampered")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := repo.Commits.Get(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(data), "add a", "add b", 1)
+	if err := repo.Commits.Put(commit, []byte(tampered)); err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []string{commit, blob} {
+		if err := repo.VerifyObject(hash); err == nil || !strings.Contains(err.Error(), "is corrupt") {
+			t.Errorf("VerifyObject(%s) after tampering = %v, want a corruption error", hash, err)
+		}
+	}
+}
+
+func TestConfigLocalOverridesGlobal(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.SetConfigScope("global", "user.name", "Global Name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetConfigScope("global", "user.email", "global@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetConfig("user.name"); err != nil || got != "Global Name" {
+		t.Errorf("GetConfig(user.name) = %q, %v; want the global value", got, err)
+	}
+	if err := repo.SetConfig("user.name", "Local Name"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"user.name": "Local Name", "user.email": "global@example.com"} {
+		if got, err := repo.GetConfig(key); err != nil || got != want {
+			t.Errorf("GetConfig(%s) = %q, %v; want %q", key, got, err, want)
+		}
+	}
+	global, err := repo.ListConfig("global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if global["user.name"] != "Global Name" {
+		t.Errorf("global user.name = %q after a local override, want it unchanged", global["user.name"])
+	}
+}
+
+func TestRemoveGlobalRemote(t *testing.T) {
+	repo := newTestRepo(t)
+	global := &Config{Settings: map[string]string{}, Remotes: map[string]string{"shared": "/srv/shared"}}
+	if err := SaveGlobalConfig(global); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddRemote("origin", "/srv/origin"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"shared", "origin"} {
+		if err := repo.RemoveRemote(name); err != nil {
+			t.Errorf("RemoveRemote(%s) = %v", name, err)
+		}
+	}
+	if remotes, err := repo.Remotes(); err != nil || len(remotes) != 0 {
+		t.Errorf("Remotes() = %v, %v after removing both, want none", remotes, err)
+	}
+	if err := repo.RemoveRemote("shared"); err == nil {
+		t.Error("removing a remote twice succeeded, want an error")
+	}
+}
+
+func TestCommitSourceDateEpoch(t *testing.T) {
+	commitWith := func(t *testing.T, epoch, date string) (*Commit, error) {
+		t.Helper()
+		repo := newTestRepo(t)
+		t.Setenv("SOURCE_DATE_EPOCH", epoch)
+		writeFile(t, "a.txt", "a")
+		if err := repo.Add("a.txt", false); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := repo.Commit("message", CommitOptions{Date: date})
+		if err != nil {
+			if head, _ := repo.Head(); head != "" {
+				t.Errorf("a failed commit moved HEAD to %s", head)
+			}
+			return nil, err
+		}
+		return repo.ReadCommit(hash)
+	}
+
+	t.Run("env", func(t *testing.T) {
+		commit, err := commitWith(t, "1700000000", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if commit.Timestamp != "2023-11-14T22:13:20Z" || commit.CommitterTimestamp != "2023-11-14T22:13:20Z" {
+			t.Errorf("timestamps = %s, %s; want both from SOURCE_DATE_EPOCH", commit.Timestamp, commit.CommitterTimestamp)
+		}
+	})
+	t.Run("date wins over env", func(t *testing.T) {
+		commit, err := commitWith(t, "1700000000", "2001-02-03T04:05:06Z")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if commit.Timestamp != "2001-02-03T04:05:06Z" {
+			t.Errorf("Timestamp = %s, want the --date value", commit.Timestamp)
+		}
+		if commit.CommitterTimestamp != "2023-11-14T22:13:20Z" {
+			t.Errorf("CommitterTimestamp = %s, want SOURCE_DATE_EPOCH", commit.CommitterTimestamp)
+		}
+	})
+	t.Run("now without env", func(t *testing.T) {
+		before := time.Now().Add(-time.Second)
+		commit, err := commitWith(t, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stamp, err := time.Parse(time.RFC3339, commit.Timestamp)
+		if err != nil || stamp.Before(before) || stamp.After(time.Now().Add(time.Second)) {
+			t.Errorf("Timestamp = %s, %v; want the current time", commit.Timestamp, err)
+		}
+	})
+	for _, epoch := range []string{"yesterday", "-1", "1.5", "1700000000 "} {
+		t.Run("invalid "+epoch, func(t *testing.T) {
+			if _, err := commitWith(t, epoch, ""); err == nil || !strings.Contains(err.Error(), "SOURCE_DATE_EPOCH") {
+				t.Errorf("commit with SOURCE_DATE_EPOCH=%q = %v, want an error naming it", epoch, err)
+			}
+		})
+	}
+}
+
+func TestPruneEmpty(t *testing.T) {
+	repo := newTestRepo(t)
+	commitFile := func(path, content, message string) {
+		t.Helper()
+		writeFile(t, path, content)
+		if err := repo.Add(path, false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Commit(message, CommitOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commitFile("a.txt", "one", "first")
+	commitFile("a.txt", "one", "same content again")
+	commitFile("b.txt", "b", "add b")
+	commitFile("a.txt", "two", "change a")
+	commitFile("a.txt", "two", "no-op at the tip")
+	before, err := repo.LsTree("HEAD", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := repo.PruneEmpty()
+	if err != nil || pruned != 2 {
+		t.Fatalf("PruneEmpty() = %d, %v; want 2", pruned, err)
+	}
+	var messages []string
+	for hash, _ := repo.Head(); hash != ""; {
+		commit, err := repo.ReadCommit(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, commit.Message)
+		hash = commit.Parent
+	}
+	if want := []string{"change a", "add b", "first"}; !slices.Equal(messages, want) {
+		t.Errorf("history after pruning = %q, want %q", messages, want)
+	}
+	after, err := repo.LsTree("HEAD", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(before, after) {
+		t.Errorf("tree after pruning = %v, want %v", after, before)
+	}
+	if pruned, err := repo.PruneEmpty(); err != nil || pruned != 0 {
+		t.Errorf("second PruneEmpty() = %d, %v; want 0", pruned, err)
+	}
+}
+
+func TestRevParse(t *testing.T) {
+	repo := newTestRepo(t)
+	var hashes []string
+	for i, content := range []string{"one", "two", "three", "four"} {
+		writeFile(t, "a.txt", content)
+		if err := repo.Add("a.txt", false); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := repo.Commit(fmt.Sprintf("commit %d", i), CommitOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	head := hashes[3]
+	for _, tc := range []struct {
+		rev, want string
+	}{
+		{"HEAD", head},
+		{"HEAD~0", head},
+		{"HEAD^0", head},
+		{"HEAD~", hashes[2]},
+		{"HEAD^", hashes[2]},
+		{"HEAD~1", hashes[2]},
+		{"HEAD~3", hashes[0]},
+		{"HEAD^^", hashes[1]},
+		{"HEAD~1^", hashes[1]},
+		{"HEAD^~2", hashes[0]},
+		{"HEAD~1^0~1", hashes[1]},
+		{head[:7] + "~2", hashes[1]},
+		{hashes[1], hashes[1]},
+	} {
+		if got, err := repo.RevParse(tc.rev); err != nil || got != tc.want {
+			t.Errorf("RevParse(%q) = %s, %v; want %s", tc.rev, got, err, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		rev, wantErr string
+	}{
+		{"HEAD~4", "past the first commit"},
+		{"HEAD~3^", "past the first commit"},
+		{"HEAD^2", "no parent 2"},
+		{"HEAD~x", "invalid revision"},
+		{"HEAD@{1}", "unknown commit"},
+		{"~1", "invalid revision"},
+		{"HEAD~1x", "invalid revision"},
+		{"abc", "too short"},
+	} {
+		if got, err := repo.RevParse(tc.rev); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("RevParse(%q) = %s, %v; want an error containing %q", tc.rev, got, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCommitOnly(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "a.txt", "a staged")
+	writeFile(t, "b.txt", "b staged")
+	if _, err := repo.AddFiles([]string{"a.txt", "b.txt"}, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "a.txt", "a edited")
+	writeFile(t, "c.txt", "c fresh")
+
+	hash, err := repo.Commit("partial", CommitOptions{Only: []string{"a.txt", "c.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.ReadCommit(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", "c.txt"}; !slices.Equal(commit.Files, want) {
+		t.Errorf("committed %q, want %q", commit.Files, want)
+	}
+	for _, path := range []string{"a.txt", "c.txt"} {
+		want, err := repo.HashFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if commit.Blobs[path] != want {
+			t.Errorf("%s committed as %s, want the working tree content %s", path, commit.Blobs[path], want)
+		}
+	}
+	if got := stagedPaths(t, repo); !slices.Equal(got, []string{"b.txt"}) {
+		t.Errorf("staged after --only = %q, want b.txt left staged", got)
+	}
+
+	if _, err := repo.Commit("missing", CommitOptions{Only: []string{"b.txt", "missing.txt"}}); err == nil || !strings.Contains(err.Error(), "neither staged nor present") {
+		t.Errorf("committing a missing path = %v, want a pathspec error", err)
+	}
+	if head, _ := repo.Head(); head != hash {
+		t.Errorf("HEAD moved to %s after a failed --only commit", head)
+	}
+	if got := stagedPaths(t, repo); !slices.Equal(got, []string{"b.txt"}) {
+		t.Errorf("staged after a failed --only commit = %q, want it unchanged", got)
+	}
+}
+
+func TestUntrackedModes(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "src/main.go", "package main")
+	if err := repo.Add("src/main.go", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"top.txt", "src/new.go", "build/b.o", "build/out/a.o"} {
+		writeFile(t, path, path)
+	}
+	for _, tc := range []struct {
+		mode string
+		want []string
+	}{
+		{"no", nil},
+		{"normal", []string{"build/", "src/new.go", "top.txt"}},
+		{"all", []string{"build/b.o", "build/out/a.o", "src/new.go", "top.txt"}},
+	} {
+		report, err := repo.Status(false, tc.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(report.Untracked, tc.want) {
+			t.Errorf("untracked with mode %s = %q, want %q", tc.mode, report.Untracked, tc.want)
+		}
+	}
+}
+
+func TestWriteDOTBranchedHistory(t *testing.T) {
+	repo := newTestRepo(t)
+	var hashes []string
+	for i, content := range []string{"one", "two", "three"} {
+		writeFile(t, "a.txt", content)
+		if err := repo.Add("a.txt", false); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := repo.Commit(fmt.Sprintf("commit %d", i), CommitOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	side := &Commit{Parent: hashes[1], Message: "side branch\n\nbody", Timestamp: "2030-01-01T00:00:00Z", Files: []string{"b.txt"}}
+	if err := repo.writeCommit(side); err != nil {
+		t.Fatal(err)
+	}
+	const bare = "0123456789abcdef0123456789abcdef01234567"
+	if err := repo.Commits.Put(bare, []byte(`{"message":"x"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := repo.WriteDOT(&out); err != nil {
+		t.Fatal(err)
+	}
+	graph := out.String()
+	for _, want := range []string{
+		fmt.Sprintf("%q [label=%q];", hashes[0], hashes[0][:7]+"\ncommit 0"),
+		fmt.Sprintf("%q [label=%q];", side.Hash, side.Hash[:7]+"\nside branch"),
+		fmt.Sprintf("%q [label=%q];", bare, bare[:7]+"\nx"),
+		fmt.Sprintf("%q -> %q;", hashes[1], hashes[0]),
+		fmt.Sprintf("%q -> %q;", hashes[2], hashes[1]),
+		fmt.Sprintf("%q -> %q;", side.Hash, hashes[1]),
+		fmt.Sprintf("\"HEAD\" -> %q [style=dashed];", hashes[2]),
+	} {
+		if !strings.Contains(graph, want) {
+			t.Errorf("graph is missing %s:\n%s", want, graph)
+		}
+	}
+	if strings.Count(graph, " -> ") != 4 {
+		t.Errorf("graph has %d edges, want 4:\n%s", strings.Count(graph, " -> "), graph)
+	}
+
+	dangling, err := repo.DanglingCommits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(dangling)
+	want := []string{bare, side.Hash}
+	slices.Sort(want)
+	if !slices.Equal(dangling, want) {
+		t.Errorf("dangling = %q, want %q", dangling, want)
+	}
+}
+
+func TestFsckLostFound(t *testing.T) {
+	repo := newTestRepo(t)
+	for _, content := range []string{"one", "two", "three"} {
+		writeFile(t, "a.txt", content)
+		if err := repo.Add("a.txt", false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Commit("commit "+content, CommitOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldHead, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.Squash("HEAD~2", "HEAD", "squashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	lostFound := filepath.Join(repo.VcsDir, "lost-found", "commit", oldHead)
+	dangling, err := repo.Fsck(false)
+	if err != nil || !slices.Equal(dangling, []string{oldHead}) {
+		t.Fatalf("Fsck(false) = %q, %v; want the squashed tip %s", dangling, err, oldHead)
+	}
+	if _, err := os.Stat(lostFound); !os.IsNotExist(err) {
+		t.Errorf("Fsck without lost-found wrote %s: %v", lostFound, err)
+	}
+	if dangling, err := repo.Fsck(true); err != nil || !slices.Equal(dangling, []string{oldHead}) {
+		t.Fatalf("Fsck(true) = %q, %v; want %s", dangling, err, oldHead)
+	}
+	if data, err := os.ReadFile(lostFound); err != nil || string(data) != oldHead+"\n" {
+		t.Errorf("%s = %q, %v; want the dangling hash", lostFound, data, err)
+	}
+}
+
+func TestAheadBehind(t *testing.T) {
+	repo := newTestRepo(t)
+	for _, content := range []string{"one", "two", "three", "four"} {
+		writeFile(t, "a.txt", content)
+		if err := repo.Add("a.txt", false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Commit("commit "+content, CommitOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldHead, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Squashing the last two commits leaves the old tip on a diverged line.
+	if _, _, err := repo.Squash("HEAD~2", "HEAD", "squashed"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, local, upstream string
+		ahead, behind         int
+	}{
+		{"up to date", "HEAD", "HEAD", 0, 0},
+		{"ahead", "HEAD", "HEAD~1", 1, 0},
+		{"behind", "HEAD~1", "HEAD", 0, 1},
+		{"behind the old tip", "HEAD~1", oldHead, 0, 2},
+		{"diverged", "HEAD", oldHead, 1, 2},
+		{"diverged reversed", oldHead, "HEAD", 2, 1},
+	} {
+		ahead, behind, err := repo.AheadBehind(tc.local, tc.upstream)
+		if err != nil || ahead != tc.ahead || behind != tc.behind {
+			t.Errorf("%s: AheadBehind(%s, %s) = %d, %d, %v; want %d, %d",
+				tc.name, tc.local, tc.upstream, ahead, behind, err, tc.ahead, tc.behind)
+		}
+	}
+}
+
+func TestCommitFixupAndSquash(t *testing.T) {
+	repo := newTestRepo(t)
+	commit := func(message string, opts CommitOptions) (*Commit, error) {
+		t.Helper()
+		writeFile(t, "a.txt", fmt.Sprintf("%s %+v", message, opts))
+		if err := repo.Add("a.txt", false); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := repo.Commit(message, opts)
+		if err != nil {
+			return nil, err
+		}
+		return repo.ReadCommit(hash)
+	}
+	target, err := commit("Fix the parser\n\nLong explanation.", CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, message string
+		opts          CommitOptions
+		want          string
+	}{
+		{"fixup", "", CommitOptions{Fixup: target.Hash[:7]}, "fixup! Fix the parser"},
+		{"squash", "", CommitOptions{Squash: target.Hash}, "squash! Fix the parser"},
+		{"squash with message", "Also handle tabs.", CommitOptions{Squash: target.Hash}, "squash! Fix the parser\n\nAlso handle tabs."},
+	} {
+		got, err := commit(tc.message, tc.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if got.Message != tc.want {
+			t.Errorf("%s: message = %q, want %q", tc.name, got.Message, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		name, message string
+		opts          CommitOptions
+		wantErr       string
+	}{
+		{"fixup with message", "extra", CommitOptions{Fixup: target.Hash}, "cannot combine --fixup with a commit message"},
+		{"fixup and squash", "", CommitOptions{Fixup: target.Hash, Squash: target.Hash}, "cannot use --fixup and --squash together"},
+		{"unknown target", "", CommitOptions{Fixup: "ffffffff"}, "unknown commit"},
+	} {
+		head, _ := repo.Head()
+		if _, err := commit(tc.message, tc.opts); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: Commit = %v, want an error containing %q", tc.name, err, tc.wantErr)
+		}
+		if after, _ := repo.Head(); after != head {
+			t.Errorf("%s: HEAD moved after a rejected commit", tc.name)
+		}
+	}
+}
+
+func TestCommitAll(t *testing.T) {
+	repo := newTestRepo(t)
+	for _, path := range []string{"a.txt", "b.txt", "gone.txt"} {
+		writeFile(t, path, path)
+	}
+	if _, err := repo.AddFiles([]string{"a.txt", "b.txt", "gone.txt"}, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit("first", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit("nothing changed", CommitOptions{All: true}); err == nil || err.Error() != "no changes to commit" {
+		t.Errorf("Commit with All and a clean tree = %v, want no changes to commit", err)
+	}
+
+	writeFile(t, "a.txt", "a edited")
+	writeFile(t, "new.txt", "untracked")
+	if err := os.Remove("gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit("bad date", CommitOptions{All: true, Date: "not a date"}); err == nil {
+		t.Error("Commit with All and a bad date succeeded")
+	}
+	if got := stagedPaths(t, repo); len(got) != 0 {
+		t.Errorf("a failed commit with All left %q staged, want the index untouched", got)
+	}
+	if _, err := repo.Commit("only", CommitOptions{All: true, Only: []string{"a.txt"}}); err == nil {
+		t.Error("Commit with All and Only succeeded, want an error")
+	}
+
+	hash, err := repo.Commit("second", CommitOptions{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.ReadCommit(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(commit.Files, []string{"a.txt"}) {
+		t.Errorf("committed %q, want only the modified tracked file", commit.Files)
+	}
+	if want, _ := repo.HashFile("a.txt"); commit.Blobs["a.txt"] != want {
+		t.Errorf("a.txt committed as %s, want the working copy %s", commit.Blobs["a.txt"], want)
+	}
+	if got := stagedPaths(t, repo); len(got) != 0 {
+		t.Errorf("staged after commit -a = %q, want nothing", got)
+	}
+	untracked, err := repo.UntrackedFiles("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(untracked, []string{"new.txt"}) {
+		t.Errorf("untracked = %q, want new.txt left alone", untracked)
+	}
+}
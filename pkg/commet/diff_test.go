@@ -0,0 +1,82 @@
+package commet
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name, a, b string
+		differ     bool
+	}{
+		{"both empty", "", "", false},
+		{"same", "one\ntwo\n", "one\ntwo\n", false},
+		{"from empty", "", "one\n", true},
+		{"to empty", "one\n", "", true},
+		{"changed", "one\ntwo\n", "one\n2\n", true},
+	} {
+		pathA, pathB := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+		writeFile(t, pathA, tc.a)
+		writeFile(t, pathB, tc.b)
+		var out bytes.Buffer
+		differ, err := DiffFiles(&out, pathA, pathB)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if differ != tc.differ || (out.Len() > 0) != tc.differ {
+			t.Errorf("%s: DiffFiles reported differ=%v with output %q, want differ=%v", tc.name, differ, out.String(), tc.differ)
+		}
+	}
+}
+
+func TestDiffFilesOutput(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFile(t, "a", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	writeFile(t, "b", "1\nTWO\n3\n4\n5\n6\n7\n8\n9\nten\n11")
+	const want = `--- a/a
++++ b/b
+@@ -1,5 +1,5 @@
+ 1
+-2
++TWO
+ 3
+ 4
+ 5
+@@ -7,4 +7,5 @@
+ 7
+ 8
+ 9
+-10
++ten
++11
+\ No newline at end of file
+`
+	var out bytes.Buffer
+	differ, err := DiffFiles(&out, "a", "b")
+	if err != nil || !differ {
+		t.Fatalf("DiffFiles = %v, %v; want a difference", differ, err)
+	}
+	if out.String() != want {
+		t.Errorf("DiffFiles wrote:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestDiffFilesMissingPath(t *testing.T) {
+	dir := t.TempDir()
+	present, missing := filepath.Join(dir, "present"), filepath.Join(dir, "missing")
+	writeFile(t, present, "one\n")
+	for _, paths := range [][2]string{{missing, present}, {present, missing}} {
+		var out bytes.Buffer
+		if _, err := DiffFiles(&out, paths[0], paths[1]); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("DiffFiles(%s, %s) = %v, want a not-exist error", paths[0], paths[1], err)
+		}
+		if out.Len() > 0 {
+			t.Errorf("DiffFiles wrote %q for a missing path, want nothing", out.String())
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package commet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+func (r *Repo) ancestors(hash string) (map[string]bool, error) {
+	seen := map[string]bool{}
+	for hash != "" && !seen[hash] {
+		commit, err := r.ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		seen[hash] = true
+		hash = commit.Parent
+	}
+	return seen, nil
+}
+
+// DanglingCommits returns the unreachable commits that no other unreachable
+// commit points to, so each lost line of history is reported once by its tip.
+func (r *Repo) DanglingCommits() ([]string, error) {
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	reachable, err := r.ancestors(head)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := r.allCommits()
+	if err != nil {
+		return nil, err
+	}
+	referenced := map[string]bool{}
+	for _, commit := range commits {
+		if !reachable[commit.Hash] && commit.Parent != "" {
+			referenced[commit.Parent] = true
+		}
+	}
+	var dangling []string
+	for _, commit := range commits {
+		if !reachable[commit.Hash] && !referenced[commit.Hash] {
+			dangling = append(dangling, commit.Hash)
+		}
+	}
+	return dangling, nil
+}
+
+func (r *Repo) Fsck(lostFound bool) ([]string, error) {
+	dangling, err := r.DanglingCommits()
+	if err != nil {
+		return nil, err
+	}
+	if !lostFound || len(dangling) == 0 {
+		return dangling, nil
+	}
+	for _, hash := range dangling {
+		if err := r.State.Put("lost-found/commit/"+hash, []byte(hash+"\n")); err != nil {
+			return nil, err
+		}
+	}
+	return dangling, nil
+}
+
+// VerifyObject rehashes the commit or blob named by hash, which may be a
+// prefix, and returns an error if its content no longer matches its name.
+func (r *Repo) VerifyObject(hash string) error {
+	commit, err := resolvePrefix(r.Commits, hash)
+	if err != nil {
+		return err
+	}
+	blob, err := resolvePrefix(r.Objects, hash)
+	if err != nil {
+		return err
+	}
+	switch {
+	case commit != "" && blob != "":
+		return fmt.Errorf("ambiguous hash prefix %q", hash)
+	case commit != "":
+		data, err := r.Commits.Get(commit)
+		if err != nil {
+			return err
+		}
+		return verifyObjectData("commits", commit, data)
+	case blob != "":
+		data, err := r.Objects.Get(blob)
+		if err != nil {
+			return err
+		}
+		return verifyObjectData("objects", blob, data)
+	}
+	return fmt.Errorf("unknown object %q", hash)
+}
+
+// verifyObjectData checks that data, stored under name in the commits or
+// objects store, hashes to name.
+func verifyObjectData(kind, name string, data []byte) error {
+	var actual string
+	if kind == "commits" {
+		var commit Commit
+		if err := json.Unmarshal(data, &commit); err != nil {
+			return fmt.Errorf("corrupt commit %s: %v", name, err)
+		}
+		actual = commit.ComputeHash()
+	} else {
+		var err error
+		if actual, err = hashStream(bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+	if actual != name {
+		return fmt.Errorf("object %s is corrupt: content hashes to %s", name, actual)
+	}
+	return nil
+}
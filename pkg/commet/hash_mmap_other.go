@@ -0,0 +1,9 @@
+//go:build !unix
+
+package commet
+
+import "os"
+
+func hashFileMmap(file *os.File, size int64) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package commet
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"syscall"
+)
+
+func hashFileMmap(file *os.File, size int64) (string, bool) {
+	if size <= 0 || int64(int(size)) != size {
+		return "", false
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return "", false
+	}
+	defer syscall.Munmap(data)
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:]), true
+}
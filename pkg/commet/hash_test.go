@@ -0,0 +1,88 @@
+package commet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func BenchmarkHashFile(b *testing.B) {
+	repo := NewMemRepo()
+	b.Run("SmallFiles", func(b *testing.B) {
+		dir := b.TempDir()
+		var paths []string
+		for i := 0; i < 1000; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("file%04d.txt", i))
+			if err := os.WriteFile(path, []byte(strings.Repeat("x", 512)), 0644); err != nil {
+				b.Fatal(err)
+			}
+			paths = append(paths, path)
+		}
+		for b.Loop() {
+			for _, path := range paths {
+				if _, err := repo.HashFile(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	path := filepath.Join(b.TempDir(), "large.bin")
+	if err := os.WriteFile(path, make([]byte, mmapHashThreshold+1), 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("LargeFile/Stream", func(b *testing.B) {
+		b.SetBytes(mmapHashThreshold + 1)
+		for b.Loop() {
+			file, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, err = hashStream(file)
+			file.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("LargeFile/Mmap", func(b *testing.B) {
+		b.SetBytes(mmapHashThreshold + 1)
+		for b.Loop() {
+			file, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, ok := hashFileMmap(file, mmapHashThreshold+1)
+			file.Close()
+			if !ok {
+				b.Skip("mmap is not available")
+			}
+		}
+	})
+}
+
+func TestHashFileMmapMatchesStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "large.bin")
+	data := []byte(strings.Repeat("0123456789abcdef", (mmapHashThreshold+16)/16))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	streamed, err := hashStream(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewMemRepo().HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != streamed {
+		t.Errorf("HashFile = %s, streaming hash = %s", got, streamed)
+	}
+}
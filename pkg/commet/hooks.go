@@ -0,0 +1,78 @@
+package commet
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runHook runs .commet/hooks/<name> from the repository root if it exists
+// and is executable. A non-zero exit aborts the operation that invoked it.
+func (r *Repo) runHook(name string, args ...string) error {
+	path, err := r.hookPath(name)
+	if err != nil || path == "" {
+		return err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(absPath, args...)
+	cmd.Dir = r.RepoDir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %v", name, err)
+	}
+	return nil
+}
+
+// hookPath returns the path of an executable hook, or "" if there is none.
+func (r *Repo) hookPath(name string) (string, error) {
+	if r.inMemory() {
+		return "", nil
+	}
+	path := filepath.Join(r.VcsDir, "hooks", name)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+		return "", nil
+	}
+	return path, nil
+}
+
+// runCommitMsgHook writes message to .commet/COMMIT_EDITMSG, runs the
+// commit-msg hook on it and returns the message as the hook left it.
+func (r *Repo) runCommitMsgHook(message string) (string, error) {
+	if hook, err := r.hookPath("commit-msg"); err != nil || hook == "" {
+		return message, err
+	}
+	path := filepath.Join(r.VcsDir, "COMMIT_EDITMSG")
+	if err := os.WriteFile(path, []byte(message+"\n"), 0644); err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if err := r.runHook("commit-msg", absPath); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	message = strings.TrimRight(string(data), "\n")
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("aborting commit due to empty commit message")
+	}
+	return message, nil
+}
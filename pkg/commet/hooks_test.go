@@ -0,0 +1,122 @@
+package commet
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeHook installs an executable shell script as the named hook.
+func writeHook(t *testing.T, repo *Repo, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are shell scripts")
+	}
+	path := filepath.Join(repo.VcsDir, "hooks", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func stageFile(t *testing.T, repo *Repo, path, content string) {
+	t.Helper()
+	writeFile(t, path, content)
+	if err := repo.Add(path, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPreCommitHook(t *testing.T) {
+	repo := newTestRepo(t)
+	writeHook(t, repo, "pre-commit", "echo ran >> hook.log\n[ ! -e block ]\n")
+	stageFile(t, repo, "a.txt", "a")
+	if _, err := repo.Commit("first", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile("hook.log"); err != nil || string(data) != "ran\n" {
+		t.Errorf("hook.log = %q, %v; want the hook to have run once", data, err)
+	}
+
+	writeFile(t, "block", "")
+	stageFile(t, repo, "a.txt", "b")
+	head, _ := repo.Head()
+	if _, err := repo.Commit("blocked", CommitOptions{}); err == nil {
+		t.Error("a commit went through although the pre-commit hook failed")
+	}
+	if after, _ := repo.Head(); after != head {
+		t.Error("HEAD moved after the pre-commit hook failed")
+	}
+	if got := stagedPaths(t, repo); len(got) != 1 {
+		t.Errorf("staged = %q after a failed hook, want a.txt still staged", got)
+	}
+	if _, err := repo.Commit("skipped", CommitOptions{NoVerify: true}); err != nil {
+		t.Errorf("Commit with NoVerify = %v, want the failing hook skipped", err)
+	}
+	if data, _ := os.ReadFile("hook.log"); string(data) != "ran\nran\n" {
+		t.Errorf("hook.log = %q, want no run for the --no-verify commit", data)
+	}
+}
+
+func TestPreCommitHookRunsAfterValidation(t *testing.T) {
+	repo := newTestRepo(t)
+	writeHook(t, repo, "pre-commit", "echo ran >> hook.log\n")
+	stageFile(t, repo, "a.txt", "a")
+	for _, opts := range []CommitOptions{
+		{Date: "not a date"},
+		{Author: "no email"},
+		{Fixup: "ffffffff"},
+	} {
+		if _, err := repo.Commit("", opts); err == nil {
+			t.Errorf("Commit(%+v) succeeded, want a validation error", opts)
+		}
+	}
+	if _, err := os.Stat("hook.log"); !os.IsNotExist(err) {
+		t.Errorf("the pre-commit hook ran for a commit with invalid options: %v", err)
+	}
+}
+
+func TestCommitMsgHook(t *testing.T) {
+	repo := newTestRepo(t)
+	writeHook(t, repo, "commit-msg", `if grep -q WIP "$1"; then
+	echo "no WIP commits" >&2
+	exit 1
+fi
+printf '\nReviewed-by: Hook <hook@example.com>\n' >> "$1"
+`)
+	stageFile(t, repo, "a.txt", "a")
+	if _, err := repo.Commit("WIP: half done", CommitOptions{}); err == nil || !strings.Contains(err.Error(), "commit-msg hook failed") {
+		t.Errorf("Commit with a rejected message = %v, want a commit-msg hook error", err)
+	}
+	if head, _ := repo.Head(); head != "" {
+		t.Errorf("HEAD moved to %s after the commit-msg hook rejected the message", head)
+	}
+
+	hash, err := repo.Commit("Add a", CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.ReadCommit(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Add a\n\nReviewed-by: Hook <hook@example.com>"; commit.Message != want {
+		t.Errorf("message = %q, want the hook's rewrite %q", commit.Message, want)
+	}
+
+	stageFile(t, repo, "a.txt", "b")
+	hash, err = repo.Commit("WIP: skipped", CommitOptions{NoVerify: true})
+	if err != nil {
+		t.Fatalf("Commit with NoVerify = %v, want the hook skipped", err)
+	}
+	if commit, err = repo.ReadCommit(hash); err != nil {
+		t.Fatal(err)
+	}
+	if commit.Message != "WIP: skipped" {
+		t.Errorf("message = %q, want it untouched by the skipped hook", commit.Message)
+	}
+}
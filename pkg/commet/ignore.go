@@ -0,0 +1,197 @@
+package commet
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type ignoreRule struct {
+	source   string
+	line     int
+	pattern  string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+type ignoreMatcher struct {
+	root   string
+	global []ignoreRule
+	cache  map[string][]ignoreRule
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{pattern: line}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.HasPrefix(line, "/") {
+		rule.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+func (r *Repo) loadIgnore() (*ignoreMatcher, error) {
+	matcher := &ignoreMatcher{root: r.RepoDir, cache: map[string][]ignoreRule{}}
+	if dir := globalConfigDir(); dir != "" {
+		rules, err := readIgnoreFile(filepath.Join(dir, "ignore"))
+		if err != nil {
+			return nil, err
+		}
+		matcher.global = rules
+	}
+	if _, err := matcher.rulesFor(""); err != nil {
+		return nil, err
+	}
+	return matcher, nil
+}
+
+func readIgnoreFile(path string) ([]ignoreRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var rules []ignoreRule
+	for i, line := range strings.Split(string(data), "\n") {
+		if rule, ok := parseIgnoreRule(line); ok {
+			rule.source = path
+			rule.line = i + 1
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (m *ignoreMatcher) rulesFor(dir string) ([]ignoreRule, error) {
+	if rules, ok := m.cache[dir]; ok {
+		return rules, nil
+	}
+	rules, err := readIgnoreFile(filepath.Join(m.root, filepath.FromSlash(dir), ".commetignore"))
+	if err != nil {
+		return nil, err
+	}
+	m.cache[dir] = rules
+	return rules, nil
+}
+
+func (m *ignoreMatcher) Match(relPath string, isDir bool) bool {
+	rule := m.matchRule(relPath, isDir)
+	return rule != nil && !rule.negate
+}
+
+func (m *ignoreMatcher) matchRule(relPath string, isDir bool) *ignoreRule {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	var matched *ignoreRule
+	for i := range m.global {
+		if rule := &m.global[i]; (!rule.dirOnly || isDir) && rule.matches(parts) {
+			matched = rule
+		}
+	}
+	for depth := 0; depth < len(parts); depth++ {
+		rules, err := m.rulesFor(strings.Join(parts[:depth], "/"))
+		if err != nil {
+			continue
+		}
+		for i := range rules {
+			if rule := &rules[i]; (!rule.dirOnly || isDir) && rule.matches(parts[depth:]) {
+				matched = rule
+			}
+		}
+	}
+	return matched
+}
+
+func (rule ignoreRule) matches(parts []string) bool {
+	if !rule.anchored {
+		ok, _ := path.Match(rule.segments[0], parts[len(parts)-1])
+		return ok
+	}
+	return matchSegments(rule.segments, parts)
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+type IgnoreMatch struct {
+	Path    string
+	Pattern string
+	Source  string
+	Line    int
+}
+
+func (r *Repo) CheckIgnore(paths []string) ([]IgnoreMatch, error) {
+	matcher, err := r.loadIgnore()
+	if err != nil {
+		return nil, err
+	}
+	var matches []IgnoreMatch
+	for _, p := range paths {
+		rel, err := r.relPath(p)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		isDir := strings.HasSuffix(p, "/")
+		if info, err := os.Stat(p); err == nil {
+			isDir = info.IsDir()
+		}
+		parts := strings.Split(rel, "/")
+		var rule *ignoreRule
+		for depth := 1; depth < len(parts) && rule == nil; depth++ {
+			if parent := matcher.matchRule(strings.Join(parts[:depth], "/"), true); parent != nil && !parent.negate {
+				rule = parent
+			}
+		}
+		if rule == nil {
+			rule = matcher.matchRule(rel, isDir)
+		}
+		if rule != nil && !rule.negate {
+			matches = append(matches, IgnoreMatch{Path: p, Pattern: rule.pattern, Source: rule.source, Line: rule.line})
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,74 @@
+package commet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+type ignoreTest struct {
+	path  string
+	isDir bool
+	want  bool
+}
+
+func checkIgnore(t *testing.T, repo *Repo, cases []ignoreTest) {
+	t.Helper()
+	matcher, err := repo.loadIgnore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range cases {
+		if got := matcher.Match(tc.path, tc.isDir); got != tc.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tc.path, tc.isDir, got, tc.want)
+		}
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, ".commetignore", "*.log\n!keep.log\nbuild/\ndocs/**/*.tmp\n")
+	checkIgnore(t, repo, []ignoreTest{
+		{"debug.log", false, true},
+		{"sub/debug.log", false, true},
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, true},
+		{"docs/a.tmp", false, true},
+		{"docs/a/b/c.tmp", false, true},
+		{"src/docs/a.tmp", false, false},
+		{"docs/a.txt", false, false},
+	})
+}
+
+func TestNestedIgnoreOverridesRoot(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, ".commetignore", "*.dat\ncache/\n")
+	writeFile(t, "vendor/.commetignore", "!*.dat\n*.txt\n")
+	checkIgnore(t, repo, []ignoreTest{
+		{"top.dat", false, true},
+		{"vendor/lib.dat", false, false},
+		{"vendor/deep/lib.dat", false, false},
+		{"vendor/notes.txt", false, true},
+		{"notes.txt", false, false},
+		{"vendor/cache", true, true},
+	})
+}
+
+func TestLocalIgnoreOverridesGlobal(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, filepath.Join(globalConfigDir(), "ignore"), "*.log\n*.swp\n")
+	checkIgnore(t, repo, []ignoreTest{
+		{"debug.log", false, true},
+		{"keep.log", false, true},
+		{"notes.swp", false, true},
+	})
+	writeFile(t, ".commetignore", "!keep.log\n")
+	checkIgnore(t, repo, []ignoreTest{
+		{"debug.log", false, true},
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		{"notes.swp", false, true},
+	})
+}
@@ -0,0 +1,126 @@
+package commet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Mirror copies every commit, object and ref from src to dst, verifying
+// hashes on the way, and returns the number of objects it transferred.
+func Mirror(src, dst string) (int, error) {
+	return MirrorContext(context.Background(), src, dst)
+}
+
+// MirrorContext is Mirror with cancellation checked between objects. Objects
+// copied before cancellation are kept, so a re-run picks up where it stopped;
+// refs are only updated once every object is in place.
+func MirrorContext(ctx context.Context, src, dst string) (int, error) {
+	from := NewRepo(src)
+	to := NewRepo(dst)
+	if info, err := os.Stat(from.VcsDir); err != nil || !info.IsDir() {
+		return 0, fmt.Errorf("%s is not a commet repository", src)
+	}
+	if err := os.MkdirAll(to.VcsDir, 0755); err != nil {
+		return 0, err
+	}
+	transferred := 0
+	stores := []struct {
+		kind     string
+		from, to Storage
+	}{
+		{"commits", from.Commits, to.Commits},
+		{"objects", from.Objects, to.Objects},
+	}
+	for _, store := range stores {
+		hashes, err := store.from.List()
+		if err != nil {
+			return transferred, err
+		}
+		for _, hash := range hashes {
+			if err := ctx.Err(); err != nil {
+				return transferred, err
+			}
+			if store.to.Has(hash) {
+				continue
+			}
+			data, err := store.from.Get(hash)
+			if err != nil {
+				return transferred, err
+			}
+			if err := verifyObjectData(store.kind, hash, data); err != nil {
+				return transferred, err
+			}
+			if err := store.to.Put(hash, data); err != nil {
+				return transferred, err
+			}
+			transferred++
+		}
+	}
+	if err := mirrorFile(filepath.Join(from.VcsDir, "HEAD"), filepath.Join(to.VcsDir, "HEAD")); err != nil {
+		return transferred, err
+	}
+	if err := mirrorRefs(filepath.Join(from.VcsDir, "refs"), filepath.Join(to.VcsDir, "refs")); err != nil {
+		return transferred, err
+	}
+	return transferred, nil
+}
+
+func mirrorFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if os.IsNotExist(err) {
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing, err := os.ReadFile(dst); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data)
+}
+
+func mirrorRefs(src, dst string) error {
+	present := map[string]bool{}
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == src {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		present[rel] = true
+		return mirrorFile(path, filepath.Join(dst, rel))
+	})
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(dst, func(path string, d os.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == dst {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		if !present[rel] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
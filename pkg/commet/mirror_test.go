@@ -0,0 +1,122 @@
+package commet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newMirrorSource returns a repository with two commits, a stored blob and a
+// note, along with the number of objects Mirror should transfer from it.
+func newMirrorSource(t *testing.T) (*Repo, int) {
+	t.Helper()
+	repo := newTestRepo(t)
+	for _, content := range []string{"one", "two"} {
+		writeFile(t, "a.txt", content)
+		if err := repo.Add("a.txt", false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Commit("commit "+content, CommitOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.HashObject("a.txt", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddNote("HEAD", "reviewed", false); err != nil {
+		t.Fatal(err)
+	}
+	return repo, 3
+}
+
+func TestMirror(t *testing.T) {
+	src, objects := newMirrorSource(t)
+	dst := filepath.Join(t.TempDir(), "dst")
+	transferred, err := Mirror(src.RepoDir, dst)
+	if err != nil || transferred != objects {
+		t.Fatalf("Mirror() = %d, %v; want %d objects", transferred, err, objects)
+	}
+	mirror := NewRepo(dst)
+	srcHead, _ := src.Head()
+	if head, err := mirror.Head(); err != nil || head != srcHead {
+		t.Errorf("mirrored HEAD = %s, %v; want %s", head, err, srcHead)
+	}
+	if note, err := mirror.Note("HEAD"); err != nil || note != "reviewed" {
+		t.Errorf("mirrored note = %q, %v; want %q", note, err, "reviewed")
+	}
+	for _, store := range []Storage{mirror.Commits, mirror.Objects} {
+		hashes, err := store.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, hash := range hashes {
+			if err := mirror.VerifyObject(hash); err != nil {
+				t.Errorf("mirrored object: %v", err)
+			}
+		}
+	}
+
+	if transferred, err := Mirror(src.RepoDir, dst); err != nil || transferred != 0 {
+		t.Errorf("second Mirror() = %d, %v; want 0", transferred, err)
+	}
+}
+
+func TestMirrorRejectsCorruptObjects(t *testing.T) {
+	src, _ := newMirrorSource(t)
+	const name = "0123456789abcdef0123456789abcdef01234567"
+	if err := src.Objects.Put(name, []byte("not what the name says")); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "dst")
+	if _, err := Mirror(src.RepoDir, dst); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("Mirror() with a corrupt object = %v, want a corruption error", err)
+	}
+	mirror := NewRepo(dst)
+	if mirror.Objects.Has(name) {
+		t.Error("the corrupt object was copied")
+	}
+	if _, err := os.Stat(filepath.Join(dst, ".commet", "HEAD")); !os.IsNotExist(err) {
+		t.Errorf("HEAD was mirrored although an object was rejected: %v", err)
+	}
+}
+
+func TestMirrorPrunesRefs(t *testing.T) {
+	src, _ := newMirrorSource(t)
+	dst := filepath.Join(t.TempDir(), "dst")
+	if _, err := Mirror(src.RepoDir, dst); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dst, ".commet", "refs", "heads", "stale")
+	writeFile(t, stale, "0123456789abcdef0123456789abcdef01234567\n")
+	if err := os.Remove(filepath.Join(src.VcsDir, "refs", "notes", "commits")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Mirror(src.RepoDir, dst); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{stale, filepath.Join(dst, ".commet", "refs", "notes", "commits")} {
+		if _, err := os.Stat(ref); !os.IsNotExist(err) {
+			t.Errorf("%s survived a mirror from a source without it: %v", ref, err)
+		}
+	}
+}
+
+func TestMirrorContextCancel(t *testing.T) {
+	src, objects := newMirrorSource(t)
+	dst := filepath.Join(t.TempDir(), "dst")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	transferred, err := MirrorContext(ctx, src.RepoDir, dst)
+	if !errors.Is(err, context.Canceled) || transferred != 0 {
+		t.Fatalf("MirrorContext with a cancelled context = %d, %v; want 0, context.Canceled", transferred, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, ".commet", "HEAD")); !os.IsNotExist(err) {
+		t.Errorf("a cancelled mirror updated HEAD: %v", err)
+	}
+	if transferred, err := MirrorContext(context.Background(), src.RepoDir, dst); err != nil || transferred != objects {
+		t.Errorf("MirrorContext after a cancel = %d, %v; want all %d objects", transferred, err, objects)
+	}
+}
@@ -0,0 +1,63 @@
+//go:build unix
+
+package commet
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCreatedFilesAreNotWorldWritable(t *testing.T) {
+	// With no umask to mask them, the modes below are exactly the ones
+	// commet asks for.
+	defer syscall.Umask(syscall.Umask(0))
+	repo := newTestRepo(t)
+	if err := repo.SetConfig("user.name", "A U Thor"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetConfigScope("global", "user.email", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "a.txt", "a")
+	if err := repo.Add("a.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.HashObject("a.txt", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit("first", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "b.txt", "b")
+	if err := repo.Add("b.txt", false); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, root := range []string{repo.VcsDir, globalConfigDir()} {
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.Mode().Perm()&0022 != 0 {
+				t.Errorf("%s has mode %v, want it writable only by its owner", path, info.Mode().Perm())
+			}
+			seen[d.Name()] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"commits", "objects", "config.json", "staged.json", "HEAD"} {
+		if !seen[name] {
+			t.Errorf("the repository has no %s to check", name)
+		}
+	}
+}
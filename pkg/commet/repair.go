@@ -0,0 +1,110 @@
+package commet
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+type RepairReport struct {
+	OldHead        string
+	NewHead        string
+	HeadRepaired   bool
+	DroppedEntries int
+	IndexCleared   bool
+}
+
+// Repair points a dangling HEAD at the newest commit with an intact history
+// and drops index entries that cannot be parsed. It never touches commits or
+// objects.
+func (r *Repo) Repair() (RepairReport, error) {
+	var report RepairReport
+	head, err := r.Head()
+	if err != nil {
+		return report, err
+	}
+	report.OldHead = head
+	if _, err := r.ancestors(head); err != nil {
+		newHead, err := r.newestIntactCommit()
+		if err != nil {
+			return report, err
+		}
+		if newHead == "" {
+			err = r.State.Delete("HEAD")
+		} else {
+			err = r.setHead(newHead)
+		}
+		if err != nil {
+			return report, err
+		}
+		report.NewHead = newHead
+		report.HeadRepaired = true
+	}
+	report.DroppedEntries, report.IndexCleared, err = r.repairStaged()
+	return report, err
+}
+
+func (r *Repo) newestIntactCommit() (string, error) {
+	hashes, err := r.Commits.List()
+	if err != nil {
+		return "", err
+	}
+	var newest *Commit
+	for _, hash := range hashes {
+		commit, err := r.ReadCommit(hash)
+		if err != nil || commit.ComputeHash() != hash {
+			continue
+		}
+		if _, err := r.ancestors(commit.Parent); err != nil {
+			continue
+		}
+		if newest == nil || committedAt(commit) > committedAt(newest) ||
+			(committedAt(commit) == committedAt(newest) && commit.Hash > newest.Hash) {
+			newest = commit
+		}
+	}
+	if newest == nil {
+		return "", nil
+	}
+	return newest.Hash, nil
+}
+
+// committedAt returns when a commit was made. The author date can be set
+// with --date, so the committer timestamp is used when there is one.
+func committedAt(commit *Commit) string {
+	if commit.CommitterTimestamp != "" {
+		return commit.CommitterTimestamp
+	}
+	return commit.Timestamp
+}
+
+func (r *Repo) repairStaged() (int, bool, error) {
+	data, err := r.State.Get("staged.json")
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return 0, false, nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		// Nothing salvageable; the files are still on disk to be re-added.
+		return 0, true, r.State.Delete("staged.json")
+	}
+	var staged []map[string]string
+	for _, item := range raw {
+		var entry map[string]string
+		if err := json.Unmarshal(item, &entry); err != nil || entry["path"] == "" || entry["hash"] == "" {
+			continue
+		}
+		staged = append(staged, entry)
+	}
+	dropped := len(raw) - len(staged)
+	if dropped == 0 {
+		return 0, false, nil
+	}
+	return dropped, false, r.writeStaged(staged)
+}
@@ -0,0 +1,34 @@
+package commet
+
+import "testing"
+
+func TestRepairPicksNewestByCommitterDate(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, "a.txt", "one")
+	if err := repo.Add("a.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if _, err := repo.Commit("first", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "a.txt", "two")
+	if err := repo.Add("a.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000100")
+	backdated, err := repo.Commit("second", CommitOptions{Date: "2001-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.setHead("0000000000000000000000000000000000000000"); err != nil {
+		t.Fatal(err)
+	}
+	report, err := repo.Repair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.NewHead != backdated {
+		t.Errorf("repair moved HEAD to %s, want the backdated but newer commit %s", report.NewHead, backdated)
+	}
+}
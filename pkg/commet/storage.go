@@ -0,0 +1,141 @@
+package commet
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage is a keyed store of byte blobs. Objects and commits are keyed by
+// their hash; repository state uses slash-separated names such as "HEAD".
+// Get reports a missing key with an error for which os.IsNotExist holds, and
+// deleting a missing key is not an error.
+type Storage interface {
+	Get(key string) ([]byte, error)
+	Put(key string, data []byte) error
+	Has(key string) bool
+	List() ([]string, error)
+	Delete(key string) error
+}
+
+// FileStorage keeps one file per object in Dir, the layout used by
+// .commet/objects and .commet/commits.
+type FileStorage struct {
+	Dir string
+}
+
+func (s *FileStorage) path(key string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(key))
+}
+
+func (s *FileStorage) Get(key string) ([]byte, error) {
+	return os.ReadFile(s.path(key))
+}
+
+func (s *FileStorage) Put(key string, data []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (s *FileStorage) Has(key string) bool {
+	_, err := os.Stat(s.path(key))
+	return err == nil
+}
+
+func (s *FileStorage) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStorage) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var keys []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), "tmp-") {
+			keys = append(keys, entry.Name())
+		}
+	}
+	return keys, nil
+}
+
+// MemStorage keeps objects in memory. It is safe for concurrent use.
+type MemStorage struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemStorage() *MemStorage {
+	return &MemStorage{objects: map[string][]byte{}}
+}
+
+func (s *MemStorage) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "get", Path: key, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *MemStorage) Put(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *MemStorage) Has(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.objects[key]
+	return ok
+}
+
+func (s *MemStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *MemStorage) List() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
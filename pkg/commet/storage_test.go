@@ -0,0 +1,125 @@
+package commet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var storageTests = []struct {
+	name string
+	run  func(t *testing.T, s Storage)
+}{
+	{"GetMissing", func(t *testing.T, s Storage) {
+		if _, err := s.Get("missing"); !os.IsNotExist(err) {
+			t.Errorf("Get(missing) = %v, want an error for which os.IsNotExist holds", err)
+		}
+		if s.Has("missing") {
+			t.Error("Has(missing) = true")
+		}
+	}},
+	{"PutGet", func(t *testing.T, s Storage) {
+		data := []byte("hello")
+		if err := s.Put("key", data); err != nil {
+			t.Fatal(err)
+		}
+		data[0] = 'j'
+		got, err := s.Get("key")
+		if err != nil || string(got) != "hello" {
+			t.Fatalf("Get(key) = %q, %v; want hello", got, err)
+		}
+		got[0] = 'c'
+		if again, _ := s.Get("key"); string(again) != "hello" {
+			t.Errorf("Get(key) = %q after changing an earlier result, want hello", again)
+		}
+		if !s.Has("key") {
+			t.Error("Has(key) = false after Put")
+		}
+	}},
+	{"Overwrite", func(t *testing.T, s Storage) {
+		for _, value := range []string{"first", "second"} {
+			if err := s.Put("key", []byte(value)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, err := s.Get("key"); err != nil || string(got) != "second" {
+			t.Errorf("Get(key) = %q, %v; want second", got, err)
+		}
+	}},
+	{"SlashKeys", func(t *testing.T, s Storage) {
+		if err := s.Put("refs/notes/commits", []byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.Get("refs/notes/commits"); err != nil || string(got) != "{}" {
+			t.Errorf("Get(refs/notes/commits) = %q, %v; want {}", got, err)
+		}
+		if err := s.Delete("refs/notes/commits"); err != nil {
+			t.Fatal(err)
+		}
+		if s.Has("refs/notes/commits") {
+			t.Error("Has(refs/notes/commits) = true after Delete")
+		}
+	}},
+	{"List", func(t *testing.T, s Storage) {
+		if keys, err := s.List(); err != nil || len(keys) != 0 {
+			t.Fatalf("List on an empty store = %q, %v; want nothing", keys, err)
+		}
+		for _, key := range []string{"c", "a", "b"} {
+			if err := s.Put(key, []byte(key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if keys, err := s.List(); err != nil || strings.Join(keys, ",") != "a,b,c" {
+			t.Errorf("List = %q, %v; want [a b c]", keys, err)
+		}
+	}},
+	{"Delete", func(t *testing.T, s Storage) {
+		if err := s.Put("key", []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete("key"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Get("key"); !os.IsNotExist(err) {
+			t.Errorf("Get after Delete = %v, want an error for which os.IsNotExist holds", err)
+		}
+		if keys, _ := s.List(); len(keys) != 0 {
+			t.Errorf("List after Delete = %q, want nothing", keys)
+		}
+		if err := s.Delete("key"); err != nil {
+			t.Errorf("deleting a missing key: %v", err)
+		}
+	}},
+}
+
+func TestStorage(t *testing.T) {
+	backends := []struct {
+		name string
+		new  func(t *testing.T) Storage
+	}{
+		{"FileStorage", func(t *testing.T) Storage { return &FileStorage{Dir: filepath.Join(t.TempDir(), "objects")} }},
+		{"MemStorage", func(t *testing.T) Storage { return NewMemStorage() }},
+	}
+	for _, backend := range backends {
+		for _, tc := range storageTests {
+			t.Run(backend.name+"/"+tc.name, func(t *testing.T) {
+				tc.run(t, backend.new(t))
+			})
+		}
+	}
+}
+
+func TestFileStorageListSkipsTempFiles(t *testing.T) {
+	s := &FileStorage{Dir: t.TempDir()}
+	if err := s.Put("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(s.Dir, "tmp-123456"), "partial write")
+	if err := os.Mkdir(filepath.Join(s.Dir, "refs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := s.List(); err != nil || strings.Join(keys, ",") != "key" {
+		t.Errorf("List = %q, %v; want [key] without temporary files or directories", keys, err)
+	}
+}
@@ -0,0 +1,57 @@
+package commet
+
+import (
+	"strings"
+)
+
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+func parseTrailerLine(line string) (Trailer, bool) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok || key == "" || strings.TrimSpace(value) == "" {
+		return Trailer{}, false
+	}
+	for _, c := range key {
+		if !(c == '-' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			return Trailer{}, false
+		}
+	}
+	return Trailer{Key: key, Value: strings.TrimSpace(value)}, true
+}
+
+func ParseTrailers(message string) []Trailer {
+	paragraphs := strings.Split(strings.TrimRight(message, "\n"), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	var trailers []Trailer
+	for _, line := range strings.Split(strings.Trim(paragraphs[len(paragraphs)-1], "\n"), "\n") {
+		if len(trailers) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			trailers[len(trailers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		trailer, ok := parseTrailerLine(line)
+		if !ok {
+			return nil
+		}
+		trailers = append(trailers, trailer)
+	}
+	return trailers
+}
+
+func AppendTrailer(message, key, value string) string {
+	trailers := ParseTrailers(message)
+	for _, trailer := range trailers {
+		if trailer.Key == key && trailer.Value == value {
+			return message
+		}
+	}
+	message = strings.TrimRight(message, "\n")
+	if len(trailers) > 0 {
+		return message + "\n" + key + ": " + value
+	}
+	return message + "\n\n" + key + ": " + value
+}
@@ -0,0 +1,75 @@
+package commet
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTrailers(t *testing.T) {
+	for _, tc := range []struct {
+		name, message string
+		want          []Trailer
+	}{
+		{"subject only", "Fix the parser", nil},
+		{"no trailers", "Fix the parser\n\nThe body explains why.\n", nil},
+		{"trailer-like subject", "Fixes: nothing", nil},
+		{"one", "Fix the parser\n\nSigned-off-by: A U Thor <a@example.com>\n",
+			[]Trailer{{"Signed-off-by", "A U Thor <a@example.com>"}}},
+		{"several", "Fix the parser\n\nBody.\n\nReviewed-by: R <r@example.com>\nFixes: #12\nSigned-off-by: A <a@example.com>",
+			[]Trailer{{"Reviewed-by", "R <r@example.com>"}, {"Fixes", "#12"}, {"Signed-off-by", "A <a@example.com>"}}},
+		{"continuation", "Fix the parser\n\nCo-authored-by: A Very Long\n  Name <long@example.com>\nFixes: #3",
+			[]Trailer{{"Co-authored-by", "A Very Long Name <long@example.com>"}, {"Fixes", "#3"}}},
+		{"mixed last paragraph", "Fix the parser\n\nFixes: #3\nand some prose", nil},
+		{"key with spaces", "Fix the parser\n\nSee also: the docs", nil},
+	} {
+		if got := ParseTrailers(tc.message); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: ParseTrailers = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAppendTrailer(t *testing.T) {
+	const signoff = "A U Thor <a@example.com>"
+	for _, tc := range []struct {
+		name, message, want string
+	}{
+		{"subject only", "Fix the parser", "Fix the parser\n\nSigned-off-by: " + signoff},
+		{"body", "Fix the parser\n\nWhy.\n", "Fix the parser\n\nWhy.\n\nSigned-off-by: " + signoff},
+		{"existing trailers", "Fix the parser\n\nFixes: #3\n", "Fix the parser\n\nFixes: #3\nSigned-off-by: " + signoff},
+		{"other sign-off", "Fix the parser\n\nSigned-off-by: B <b@example.com>",
+			"Fix the parser\n\nSigned-off-by: B <b@example.com>\nSigned-off-by: " + signoff},
+	} {
+		got := AppendTrailer(tc.message, "Signed-off-by", signoff)
+		if got != tc.want {
+			t.Errorf("%s: AppendTrailer = %q, want %q", tc.name, got, tc.want)
+		}
+		if again := AppendTrailer(got, "Signed-off-by", signoff); again != got {
+			t.Errorf("%s: signing off twice gave %q, want %q", tc.name, again, got)
+		}
+	}
+}
+
+func TestCommitSignoffIsIdempotent(t *testing.T) {
+	repo := newTestRepo(t)
+	for key, value := range map[string]string{"user.name": "A U Thor", "user.email": "a@example.com"} {
+		if err := repo.SetConfig(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, "a.txt", "a")
+	if err := repo.Add("a.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	const message = "Add a\n\nSigned-off-by: A U Thor <a@example.com>"
+	hash, err := repo.Commit(message, CommitOptions{Signoff: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.ReadCommit(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Message != message {
+		t.Errorf("message = %q, want the existing sign-off kept once", commit.Message)
+	}
+}
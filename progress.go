@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// progress reports how far a multi-file operation has got. On a terminal it
+// redraws one line; otherwise it prints a line at most once a second.
+type progress struct {
+	label string
+	out   *os.File
+	tty   bool
+	last  time.Time
+}
+
+func newProgress(label string, out *os.File) *progress {
+	return &progress{label: label, out: out, tty: isTerminal(out), last: time.Now()}
+}
+
+func (p *progress) Update(done, total int) {
+	if total <= 1 {
+		return
+	}
+	if p.tty {
+		fmt.Fprintf(p.out, "\r%s %d/%d files", p.label, done, total)
+		if done == total {
+			fmt.Fprintln(p.out)
+		}
+		return
+	}
+	if done == total || time.Since(p.last) >= time.Second {
+		fmt.Fprintf(p.out, "%s %d/%d files\n", p.label, done, total)
+		p.last = time.Now()
+	}
+}